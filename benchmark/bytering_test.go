@@ -0,0 +1,47 @@
+package benchmark
+
+import (
+	"io"
+	"testing"
+
+	"github.com/amirylm/lockfree/bytering"
+)
+
+// BenchmarkByteRing compares piping bytes between goroutines with a byte ring and io.Pipe.
+func BenchmarkByteRing(b *testing.B) {
+	pipes := []struct {
+		name string
+		new  func() (io.Reader, io.WriteCloser)
+	}{
+		{"byte ring", func() (io.Reader, io.WriteCloser) {
+			r := bytering.New(bytering.WithSize(64 * 1024))
+			return r, r
+		}},
+		{"byte ring (multi producer)", func() (io.Reader, io.WriteCloser) {
+			r := bytering.New(bytering.WithSize(64*1024), bytering.WithMultiProducer())
+			return r, r
+		}},
+		{"io pipe", func() (io.Reader, io.WriteCloser) {
+			return io.Pipe()
+		}},
+	}
+	for _, tc := range pipes {
+		b.Run(tc.name, func(b *testing.B) {
+			r, w := tc.new()
+			chunk := make([]byte, 1024)
+			b.SetBytes(int64(len(chunk)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			go func() {
+				for i := 0; i < b.N; i++ {
+					if _, err := w.Write(chunk); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+				_ = w.Close()
+			}()
+			_, _ = io.Copy(io.Discard, r)
+		})
+	}
+}
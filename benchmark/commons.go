@@ -20,18 +20,36 @@ func BenchEnqueueDequeueBytes(b *testing.B, c, r, w int) {
 			r,
 			w,
 		},
+		{
+			"ring buffer queue (seq)",
+			ringbuffer.NewSeq[[]byte](core.WithCapacity(c)),
+			r,
+			w,
+		},
 		{
 			"ring buffer queue (lock)",
 			rb_lock.New[[]byte](core.WithCapacity(c)),
 			r,
 			w,
 		},
+		{
+			"ring buffer queue (sharded)",
+			ringbuffer.NewSharded[[]byte](0, core.WithCapacity(c)),
+			r,
+			w,
+		},
 		{
 			"linked list queue",
 			queue.New[[]byte](core.WithCapacity(c)),
 			r,
 			w,
 		},
+		{
+			"linked list queue (sharded)",
+			queue.NewSharded[[]byte](0, core.WithCapacity(c)),
+			r,
+			w,
+		},
 		{
 			"go chan",
 			gochan.New[[]byte](core.WithCapacity(c)),
@@ -59,18 +77,36 @@ func BenchEnqueueDequeueInt(b *testing.B, c, r, w int) {
 			r,
 			w,
 		},
+		{
+			"ring buffer queue (seq)",
+			ringbuffer.NewSeq[int](core.WithCapacity(c)),
+			r,
+			w,
+		},
 		{
 			"ring buffer queue (lock)",
 			rb_lock.New[int](core.WithCapacity(c)),
 			r,
 			w,
 		},
+		{
+			"ring buffer queue (sharded)",
+			ringbuffer.NewSharded[int](0, core.WithCapacity(c)),
+			r,
+			w,
+		},
 		{
 			"linked list queue",
 			queue.New[int](core.WithCapacity(c)),
 			r,
 			w,
 		},
+		{
+			"linked list queue (sharded)",
+			queue.NewSharded[int](0, core.WithCapacity(c)),
+			r,
+			w,
+		},
 		{
 			"go chan",
 			gochan.New[int](core.WithCapacity(c)),
@@ -0,0 +1,92 @@
+package benchmark
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/amirylm/lockfree/benchmark/gochan"
+	"github.com/amirylm/lockfree/core"
+	"github.com/amirylm/lockfree/queue"
+	"github.com/amirylm/lockfree/ringbuffer"
+)
+
+// BenchmarkContention measures enqueue/dequeue pairs, where the given number of goroutines
+// are producing and consuming at the same time.
+func BenchmarkContention(b *testing.B) {
+	queues := []struct {
+		name string
+		new  func() core.Queue[int]
+	}{
+		{"ring buffer queue", func() core.Queue[int] {
+			return ringbuffer.New[int](core.WithCapacity(1024))
+		}},
+		{"ring buffer queue (seq)", func() core.Queue[int] {
+			return ringbuffer.NewSeq[int](core.WithCapacity(1024))
+		}},
+		{"ring buffer queue (sharded)", func() core.Queue[int] {
+			return ringbuffer.NewSharded[int](0, core.WithCapacity(1024))
+		}},
+		{"linked list queue", func() core.Queue[int] {
+			return queue.New[int](core.WithCapacity(1024))
+		}},
+		{"linked list queue (sharded)", func() core.Queue[int] {
+			return queue.NewSharded[int](0, core.WithCapacity(1024))
+		}},
+		{"go chan", func() core.Queue[int] {
+			return gochan.New[int](core.WithCapacity(1024))
+		}},
+	}
+	for _, goroutines := range []int{1, 4, 16, 64} {
+		for _, tc := range queues {
+			b.Run(fmt.Sprintf("%s %d G", tc.name, goroutines), func(b *testing.B) {
+				q := tc.new()
+				per := b.N/goroutines + 1
+				var wg sync.WaitGroup
+				b.ReportAllocs()
+				b.ResetTimer()
+				for g := 0; g < goroutines; g++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						for i := 0; i < per; i++ {
+							for !q.Enqueue(i) {
+								runtime.Gosched()
+							}
+							for {
+								if _, ok := q.Dequeue(); ok {
+									break
+								}
+								runtime.Gosched()
+							}
+						}
+					}()
+				}
+				wg.Wait()
+			})
+		}
+	}
+}
+
+// BenchmarkNew measures the allocations of creating queues with a large capacity.
+func BenchmarkNew(b *testing.B) {
+	b.Run("ring buffer queue", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = ringbuffer.New[int](core.WithCapacity(1024))
+		}
+	})
+	b.Run("ring buffer queue (seq)", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = ringbuffer.NewSeq[int](core.WithCapacity(1024))
+		}
+	})
+	b.Run("linked list queue", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = queue.New[int](core.WithCapacity(1024))
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/amirylm/go-options"
+	"github.com/amirylm/lockfree/idle"
+	"github.com/amirylm/lockfree/reactor"
+)
+
+type countService struct {
+	handled atomic.Int64
+}
+
+func (s *countService) Select(int) bool {
+	return true
+}
+
+func (s *countService) Handle(int) {
+	s.handled.Add(1)
+}
+
+func BenchmarkDemux_IdleLatency(b *testing.B) {
+	strategies := []struct {
+		name        string
+		newStrategy func() idle.Strategy
+	}{
+		{"busy spin", idle.BusySpin},
+		{"yield", idle.Yield},
+		{"backoff", func() idle.Strategy { return idle.Backoff() }},
+		{"park", idle.Park},
+	}
+
+	for _, s := range strategies {
+		b.Run(s.name, func(b *testing.B) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			d := reactor.NewDemux(reactor.WithIdleStrategy[int](s.newStrategy()))
+			go func() {
+				_ = d.Start(ctx)
+			}()
+			defer func() {
+				_ = d.Close()
+			}()
+			svc := &countService{}
+			d.Register("count", svc, 0)
+			// ensures the service is registered
+			d.Enqueue(0)
+			for svc.handled.Load() == 0 {
+				runtime.Gosched()
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 1; i <= b.N; i++ {
+				d.Enqueue(i)
+				for svc.handled.Load() <= int64(i) {
+					runtime.Gosched()
+				}
+			}
+		})
+	}
+}
+
+type topicEvent struct {
+	topic string
+}
+
+// topicService selects only the events of its own topic
+type topicService struct {
+	topic   string
+	handled *atomic.Int64
+}
+
+func (s *topicService) Select(e topicEvent) bool {
+	return e.topic == s.topic
+}
+
+func (s *topicService) Handle(topicEvent) {
+	s.handled.Add(1)
+}
+
+func BenchmarkDemux_Routing(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		topics := make([]string, n)
+		for i := range topics {
+			topics[i] = fmt.Sprintf("topic-%d", i)
+		}
+		for _, indexed := range []bool{false, true} {
+			name := fmt.Sprintf("select/services=%d", n)
+			if indexed {
+				name = fmt.Sprintf("topic/services=%d", n)
+			}
+			b.Run(name, func(b *testing.B) {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				var opts []options.Option[reactor.DemuxOptions[topicEvent]]
+				if indexed {
+					opts = append(opts, reactor.WithTopic(func(e topicEvent) string {
+						return e.topic
+					}))
+				}
+				d := reactor.NewDemux(append(opts, reactor.WithControlQueueCapcity[topicEvent](n))...)
+				go func() {
+					_ = d.Start(ctx)
+				}()
+				defer func() {
+					_ = d.Close()
+				}()
+				handled := &atomic.Int64{}
+				for _, topic := range topics {
+					svc := &topicService{topic: topic, handled: handled}
+					if indexed {
+						d.RegisterTopic(topic, topic, svc, 0)
+						continue
+					}
+					d.Register(topic, svc, 0)
+				}
+				<-d.Ready()
+				for len(d.Services()) < n {
+					runtime.Gosched()
+				}
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 1; i <= b.N; i++ {
+					d.Enqueue(topicEvent{topic: topics[i%n]})
+					for handled.Load() < int64(i) {
+						runtime.Gosched()
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/amirylm/lockfree/disruptor"
+	"github.com/amirylm/lockfree/reactor"
+)
+
+// BenchmarkFanOut compares the disruptor with the demultiplexer, where every consumer gets every event.
+func BenchmarkFanOut(b *testing.B) {
+	for _, consumers := range []int{1, 3, 8} {
+		b.Run(fmt.Sprintf("disruptor/consumers=%d", consumers), func(b *testing.B) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			d := disruptor.New[int](disruptor.WithSize(1024))
+			handled := &atomic.Int64{}
+			handlers := make([]disruptor.Handler[int], consumers)
+			for i := range handlers {
+				handlers[i] = func(*int, int64, bool) {
+					handled.Add(1)
+				}
+			}
+			_, _ = d.Handle(handlers...)
+			go func() {
+				_ = d.Start(ctx)
+			}()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = d.PublishEvent(ctx, func(e *int) {
+					*e = i
+				})
+			}
+			for handled.Load() < int64(b.N*consumers) {
+				runtime.Gosched()
+			}
+		})
+
+		b.Run(fmt.Sprintf("demux/consumers=%d", consumers), func(b *testing.B) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			d := reactor.NewDemux[int]()
+			go func() {
+				_ = d.Start(ctx)
+			}()
+			handled := &atomic.Int64{}
+			for i := 0; i < consumers; i++ {
+				_ = d.RegisterSync(ctx, fmt.Sprintf("consumer-%d", i), &fanOutService{handled: handled}, 0)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				d.Enqueue(i)
+				// the demultiplexer drops events once the queue is full
+				for int64(i*consumers)-handled.Load() > 512 {
+					runtime.Gosched()
+				}
+			}
+			for handled.Load() < int64(b.N*consumers) {
+				runtime.Gosched()
+			}
+		})
+	}
+}
+
+type fanOutService struct {
+	handled *atomic.Int64
+}
+
+func (s *fanOutService) Select(int) bool {
+	return true
+}
+
+func (s *fanOutService) Handle(int) {
+	s.handled.Add(1)
+}
@@ -0,0 +1,51 @@
+package benchmark
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/amirylm/lockfree/executor"
+)
+
+// BenchmarkExecutor compares the executor with goroutine per task, running small hashing tasks.
+func BenchmarkExecutor(b *testing.B) {
+	data := []byte("benchmark task data")
+	task := func(wg *sync.WaitGroup) func() {
+		return func() {
+			defer wg.Done()
+			_ = sha256.Sum256(data)
+		}
+	}
+
+	b.Run("goroutine per task", func(b *testing.B) {
+		var wg sync.WaitGroup
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			wg.Add(1)
+			go task(&wg)()
+		}
+		wg.Wait()
+	})
+
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("executor/workers=%d", workers), func(b *testing.B) {
+			e := executor.New(executor.WithWorkers(workers))
+			defer func() {
+				_ = e.Close()
+			}()
+			ctx := context.Background()
+			var wg sync.WaitGroup
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				wg.Add(1)
+				_ = e.Submit(ctx, task(&wg))
+			}
+			wg.Wait()
+		})
+	}
+}
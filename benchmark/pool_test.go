@@ -0,0 +1,55 @@
+package benchmark
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/amirylm/lockfree/pool"
+)
+
+// BenchmarkPool compares the bounded and sharded pools with sync.Pool, under parallel get/put.
+func BenchmarkPool(b *testing.B) {
+	gen := func() *[64]byte {
+		return new([64]byte)
+	}
+
+	b.Run("sync.Pool", func(b *testing.B) {
+		p := sync.Pool{New: func() any {
+			return gen()
+		}}
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				r := p.Get().(*[64]byte)
+				r[0]++
+				p.Put(r)
+			}
+		})
+	})
+
+	pools := []struct {
+		name string
+		p    *pool.Bounded[*[64]byte]
+	}{
+		{"bounded", pool.NewBounded(gen, pool.WithMaxSize[*[64]byte](256))},
+		{"sharded", pool.NewSharded(gen, 0, pool.WithMaxSize[*[64]byte](256))},
+	}
+	for _, tc := range pools {
+		b.Run(tc.name, func(b *testing.B) {
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					r, err := tc.p.Get(ctx)
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					r[0]++
+					tc.p.Put(r)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,395 @@
+// Package bytering provides a contiguous byte ring buffer, used to pipe bytes between goroutines.
+package bytering
+
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/amirylm/go-options"
+	"github.com/amirylm/lockfree/idle"
+	"github.com/amirylm/lockfree/internal/pad"
+)
+
+var (
+	// ErrClosed is returned when writing to a closed ring
+	ErrClosed = errors.New("ring is closed")
+	// ErrWouldBlock is returned by non-blocking rings when an operation can't be completed without waiting
+	ErrWouldBlock = errors.New("operation would block")
+	// ErrMultiProducer is returned by zero-copy writes of multi-producer rings
+	ErrMultiProducer = errors.New("zero-copy writes require a single producer")
+)
+
+// Options is the configuration of byte rings
+type Options struct {
+	size          int
+	nonBlocking   bool
+	multiProducer bool
+	idleStrategy  func() idle.Strategy
+}
+
+// WithSize sets the size of the ring in bytes, rounded up to a power of 2, defaults to 4096.
+func WithSize(n int) options.Option[Options] {
+	return func(o *Options) {
+		o.size = n
+	}
+}
+
+// WithNonBlocking makes reads and writes return ErrWouldBlock instead of waiting for data or space.
+func WithNonBlocking() options.Option[Options] {
+	return func(o *Options) {
+		o.nonBlocking = true
+	}
+}
+
+// WithMultiProducer allows multiple goroutines to write concurrently (MPSC).
+// Writes that fit in the ring are not interleaved with other writes.
+func WithMultiProducer() options.Option[Options] {
+	return func(o *Options) {
+		o.multiProducer = true
+	}
+}
+
+// WithIdleStrategy sets the idle strategy of blocking reads and writes, defaults to idle.Backoff.
+// Waiting readers are signaled once bytes are written, and waiting writers once bytes are consumed.
+func WithIdleStrategy(f func() idle.Strategy) options.Option[Options] {
+	return func(o *Options) {
+		o.idleStrategy = f
+	}
+}
+
+// Ring is a lock-free byte ring buffer with a single consumer, and a single producer unless created
+// with WithMultiProducer. Bytes are stored contiguously, and can be accessed without copying
+// with Reserve/Commit (producer) and Peek/Consume (consumer).
+// Once closed, writes fail with ErrClosed while reads return the remaining bytes and then io.EOF.
+type Ring struct {
+	_ pad.CacheLine
+	// head is the read position, updated only by the consumer
+	head atomic.Uint64
+	_    pad.CacheLine
+	// tail is the position up to which bytes were written
+	tail atomic.Uint64
+	_    pad.CacheLine
+	// claimed is the position up to which producers reserved space, used only by multi-producer rings
+	claimed atomic.Uint64
+	_       pad.CacheLine
+
+	buf    []byte
+	mask   uint64
+	closed atomic.Bool
+	opts   *Options
+	// readers and writers that are waiting, signaled once bytes are written or consumed
+	readers, writers idle.Waiters
+}
+
+// New creates a new byte ring
+func New(opts ...options.Option[Options]) *Ring {
+	o := options.Apply(nil, opts...)
+	if o.size == 0 {
+		o.size = 4096
+	}
+	if o.idleStrategy == nil {
+		o.idleStrategy = func() idle.Strategy {
+			return idle.Backoff()
+		}
+	}
+	size := 1
+	for size < o.size {
+		size <<= 1
+	}
+	return &Ring{
+		buf:  make([]byte, size),
+		mask: uint64(size - 1),
+		opts: o,
+	}
+}
+
+// Len returns the number of bytes that can be read
+func (r *Ring) Len() int {
+	return int(r.tail.Load() - r.head.Load())
+}
+
+// Cap returns the size of the ring
+func (r *Ring) Cap() int {
+	return len(r.buf)
+}
+
+// Free returns the number of bytes that can be written
+func (r *Ring) Free() int {
+	return r.Cap() - int(r.writePos()-r.head.Load())
+}
+
+// Close closes the ring for writing, pending bytes can still be read.
+// NOTE: writes that are in progress while closing might be lost, the ring should be closed once writers are done.
+func (r *Ring) Close() error {
+	r.closed.Store(true)
+	r.readers.Signal()
+	r.writers.Signal()
+	return nil
+}
+
+// Read reads up to len(p) bytes, waiting for at least one byte unless the ring is non-blocking.
+// Returns io.EOF once the ring is closed and drained.
+func (r *Ring) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	head, tail, err := r.readable()
+	if err != nil {
+		return 0, err
+	}
+	n := min(len(p), int(tail-head))
+	i := int(head & r.mask)
+	copied := copy(p[:n], r.buf[i:])
+	copy(p[copied:n], r.buf)
+	r.head.Store(head + uint64(n))
+	r.writers.Signal()
+	return n, nil
+}
+
+// Write writes all the bytes of p, waiting for free space unless the ring is non-blocking.
+// Non-blocking rings return the number of written bytes with ErrWouldBlock once the ring is full.
+func (r *Ring) Write(p []byte) (int, error) {
+	if r.opts.multiProducer {
+		return r.writeShared(p)
+	}
+	w := waiter{ws: &r.writers}
+	defer w.done()
+	written := 0
+	for written < len(p) {
+		if r.closed.Load() {
+			return written, ErrClosed
+		}
+		tail := r.tail.Load()
+		free := r.Cap() - int(tail-r.head.Load())
+		if free == 0 {
+			if r.opts.nonBlocking {
+				return written, ErrWouldBlock
+			}
+			w.wait(r.opts.idleStrategy)
+			continue
+		}
+		chunk := p[written:]
+		chunk = chunk[:min(len(chunk), free)]
+		r.copyAt(tail, chunk)
+		r.tail.Store(tail + uint64(len(chunk)))
+		r.readers.Signal()
+		written += len(chunk)
+		w.reset()
+	}
+	return written, nil
+}
+
+// writeShared writes with multiple producers, where space is claimed with CAS and commits are done in
+// the order of claims. Chunks are written as a whole, so writes that fit in the ring are not interleaved.
+func (r *Ring) writeShared(p []byte) (int, error) {
+	w := waiter{ws: &r.writers}
+	defer w.done()
+	written := 0
+	for written < len(p) {
+		if r.closed.Load() {
+			return written, ErrClosed
+		}
+		chunk := p[written:]
+		chunk = chunk[:min(len(chunk), r.Cap())]
+		pos := r.claimed.Load()
+		free := r.Cap() - int(pos-r.head.Load())
+		if free < len(chunk) {
+			if r.opts.nonBlocking {
+				return written, ErrWouldBlock
+			}
+			w.wait(r.opts.idleStrategy)
+			continue
+		}
+		if !r.claimed.CompareAndSwap(pos, pos+uint64(len(chunk))) {
+			continue
+		}
+		r.copyAt(pos, chunk)
+		// waiting for producers that claimed space before
+		for r.tail.Load() != pos {
+			runtime.Gosched()
+		}
+		r.tail.Store(pos + uint64(len(chunk)))
+		r.readers.Signal()
+		written += len(chunk)
+		w.reset()
+	}
+	return written, nil
+}
+
+// Reserve returns a contiguous slice of up to n free bytes to write into, without copying.
+// The slice might be shorter than n in case there is less free space or the ring wraps around.
+// Written bytes are visible to the consumer once committed, see Commit.
+// Returns ErrMultiProducer for multi-producer rings.
+func (r *Ring) Reserve(n int) ([]byte, error) {
+	if r.opts.multiProducer {
+		return nil, ErrMultiProducer
+	}
+	w := waiter{ws: &r.writers}
+	defer w.done()
+	for {
+		if r.closed.Load() {
+			return nil, ErrClosed
+		}
+		tail := r.tail.Load()
+		free := r.Cap() - int(tail-r.head.Load())
+		if free > 0 {
+			i := int(tail & r.mask)
+			n = min(n, free, r.Cap()-i)
+			return r.buf[i : i+n], nil
+		}
+		if r.opts.nonBlocking {
+			return nil, ErrWouldBlock
+		}
+		w.wait(r.opts.idleStrategy)
+	}
+}
+
+// Commit makes n bytes of the last reserved slice visible to the consumer.
+func (r *Ring) Commit(n int) {
+	tail := r.tail.Load()
+	n = min(n, r.Cap()-int(tail-r.head.Load()))
+	r.tail.Store(tail + uint64(n))
+	r.readers.Signal()
+}
+
+// Peek returns a contiguous slice of the bytes that can be read, without copying.
+// The slice might hold less than Len bytes in case the ring wraps around.
+// It waits for at least one byte unless the ring is non-blocking, and returns io.EOF once the ring is closed and drained.
+// The bytes are valid until they are consumed, see Consume.
+func (r *Ring) Peek() ([]byte, error) {
+	head, tail, err := r.readable()
+	if err != nil {
+		return nil, err
+	}
+	i := int(head & r.mask)
+	n := min(int(tail-head), r.Cap()-i)
+	return r.buf[i : i+n], nil
+}
+
+// Consume discards the next n bytes, usually after reading them with Peek.
+func (r *Ring) Consume(n int) {
+	head := r.head.Load()
+	n = min(n, int(r.tail.Load()-head))
+	r.head.Store(head + uint64(n))
+	r.writers.Signal()
+}
+
+// WriteTo writes the bytes of the ring to w until the ring is closed and drained,
+// non-blocking rings return once the ring is empty.
+func (r *Ring) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for {
+		b, err := r.Peek()
+		if err != nil {
+			// peek fails only when closed or non-blocking
+			return total, nil
+		}
+		n, err := w.Write(b)
+		r.Consume(n)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// ReadFrom reads from src into the ring until io.EOF.
+// Non-blocking rings return ErrWouldBlock once the ring is full.
+func (r *Ring) ReadFrom(src io.Reader) (int64, error) {
+	if r.opts.multiProducer {
+		// reading into a buffer, as zero-copy writes are not available with multiple producers
+		return io.CopyBuffer(writerOnly{r}, src, make([]byte, min(r.Cap(), 32*1024)))
+	}
+	var total int64
+	for {
+		b, err := r.Reserve(r.Cap())
+		if err != nil {
+			return total, err
+		}
+		n, err := src.Read(b)
+		r.Commit(n)
+		total += int64(n)
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// readable waits for bytes to read, and returns the read and write positions
+func (r *Ring) readable() (uint64, uint64, error) {
+	w := waiter{ws: &r.readers}
+	defer w.done()
+	for {
+		head, tail := r.head.Load(), r.tail.Load()
+		if tail > head {
+			return head, tail, nil
+		}
+		if r.closed.Load() {
+			// bytes might be written right before closing
+			if r.tail.Load() == head {
+				return head, head, io.EOF
+			}
+			continue
+		}
+		if r.opts.nonBlocking {
+			return head, tail, ErrWouldBlock
+		}
+		w.wait(r.opts.idleStrategy)
+	}
+}
+
+// copyAt copies b into the ring at the given position, wrapping around the end of the buffer
+func (r *Ring) copyAt(pos uint64, b []byte) {
+	copied := copy(r.buf[pos&r.mask:], b)
+	copy(r.buf, b[copied:])
+}
+
+func (r *Ring) writePos() uint64 {
+	if r.opts.multiProducer {
+		return r.claimed.Load()
+	}
+	return r.tail.Load()
+}
+
+// waiter creates the idle strategy only once waiting is needed,
+// and registers it so the other side of the ring can signal it.
+type waiter struct {
+	ws        *idle.Waiters
+	s         idle.Strategy
+	w         *idle.Waiter
+	idleCount int
+}
+
+func (w *waiter) wait(newStrategy func() idle.Strategy) {
+	if w.s == nil {
+		w.s = newStrategy()
+		w.w = w.ws.Add(w.s)
+		// not idling yet, as a signal might have been sent before registering
+		return
+	}
+	w.idleCount++
+	w.s.Idle(context.Background(), w.idleCount)
+}
+
+// done unregisters the idle strategy, once the waiter returns
+func (w *waiter) done() {
+	if w.w != nil {
+		w.ws.Remove(w.w)
+	}
+}
+
+// reset is called once progress was made, so the next wait starts from the first idle iteration
+func (w *waiter) reset() {
+	w.idleCount = 0
+}
+
+// writerOnly hides ReadFrom, to avoid io.CopyBuffer from calling it recursively
+type writerOnly struct {
+	io.Writer
+}
@@ -0,0 +1,227 @@
+package bytering
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/amirylm/go-options"
+	"github.com/amirylm/lockfree/idle"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRing_ReadWrite(t *testing.T) {
+	data := make([]byte, 1<<18)
+	_, _ = rand.Read(data)
+
+	tests := []struct {
+		name string
+		r    *Ring
+	}{
+		{"single producer", New(WithSize(100))},
+		{"multi producer", New(WithSize(100), WithMultiProducer())},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, 128, tc.r.Cap())
+			go func() {
+				// writing in uneven chunks, to cover the wrap around
+				for i := 0; i < len(data); i += 77 {
+					_, err := tc.r.Write(data[i:min(i+77, len(data))])
+					require.NoError(t, err)
+				}
+				require.NoError(t, tc.r.Close())
+			}()
+			var out bytes.Buffer
+			buf := make([]byte, 50)
+			for {
+				n, err := tc.r.Read(buf)
+				out.Write(buf[:n])
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+			}
+			require.Equal(t, data, out.Bytes())
+
+			_, err := tc.r.Write([]byte{1})
+			require.ErrorIs(t, err, ErrClosed)
+		})
+	}
+}
+
+func TestRing_Copy(t *testing.T) {
+	data := make([]byte, 1<<20)
+	_, _ = rand.Read(data)
+
+	for _, opts := range [][]options.Option[Options]{{}, {WithMultiProducer()}} {
+		r := New(append(opts, WithSize(1024))...)
+		go func() {
+			// uses ReadFrom
+			_, err := io.Copy(r, bytes.NewReader(data))
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+		}()
+		var out bytes.Buffer
+		// uses WriteTo
+		n, err := io.Copy(&out, r)
+		require.NoError(t, err)
+		require.Equal(t, int64(len(data)), n)
+		require.Equal(t, data, out.Bytes())
+	}
+}
+
+func TestRing_NonBlocking(t *testing.T) {
+	r := New(WithSize(8), WithNonBlocking())
+	n, err := r.Read(make([]byte, 4))
+	require.ErrorIs(t, err, ErrWouldBlock)
+	require.Zero(t, n)
+
+	n, err = r.Write([]byte("0123456789"))
+	require.ErrorIs(t, err, ErrWouldBlock)
+	require.Equal(t, 8, n)
+	require.Zero(t, r.Free())
+	_, err = r.Reserve(1)
+	require.ErrorIs(t, err, ErrWouldBlock)
+
+	buf := make([]byte, 10)
+	n, err = r.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, "01234567", string(buf[:n]))
+
+	var out bytes.Buffer
+	_, err = r.Write([]byte("abc"))
+	require.NoError(t, err)
+	// returns once empty, as the ring is not closed
+	written, err := r.WriteTo(&out)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), written)
+
+	// multi producer writes are not split
+	mp := New(WithSize(8), WithNonBlocking(), WithMultiProducer())
+	_, err = mp.Write([]byte("012345"))
+	require.NoError(t, err)
+	n, err = mp.Write([]byte("abc"))
+	require.ErrorIs(t, err, ErrWouldBlock)
+	require.Zero(t, n)
+}
+
+func TestRing_ZeroCopy(t *testing.T) {
+	r := New(WithSize(8))
+	b, err := r.Reserve(6)
+	require.NoError(t, err)
+	require.Len(t, b, 6)
+	copy(b, "012345")
+	// bytes are not visible before commit
+	require.Zero(t, r.Len())
+	r.Commit(6)
+	require.Equal(t, 6, r.Len())
+
+	b, err = r.Peek()
+	require.NoError(t, err)
+	require.Equal(t, "012345", string(b))
+	r.Consume(4)
+
+	// the reserved slice is cut at the end of the buffer
+	b, err = r.Reserve(4)
+	require.NoError(t, err)
+	require.Len(t, b, 2)
+	copy(b, "ab")
+	r.Commit(2)
+	b, err = r.Reserve(4)
+	require.NoError(t, err)
+	require.Len(t, b, 4)
+	copy(b, "cd")
+	r.Commit(2)
+
+	b, err = r.Peek()
+	require.NoError(t, err)
+	require.Equal(t, "45ab", string(b))
+	r.Consume(len(b))
+	b, err = r.Peek()
+	require.NoError(t, err)
+	require.Equal(t, "cd", string(b))
+	r.Consume(len(b))
+	require.Zero(t, r.Len())
+
+	_, err = New(WithMultiProducer()).Reserve(1)
+	require.ErrorIs(t, err, ErrMultiProducer)
+}
+
+func TestRing_MultiProducer(t *testing.T) {
+	r := New(WithSize(256), WithMultiProducer())
+	writers, n := 8, 2000
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			record := make([]byte, 8)
+			for i := 0; i < n; i++ {
+				binary.BigEndian.PutUint32(record, uint32(w))
+				binary.BigEndian.PutUint32(record[4:], uint32(i))
+				_, err := r.Write(record)
+				require.NoError(t, err)
+			}
+		}(w)
+	}
+	go func() {
+		wg.Wait()
+		_ = r.Close()
+	}()
+
+	// records are not interleaved, and the order of each writer is kept
+	next := make([]uint32, writers)
+	record := make([]byte, 8)
+	for {
+		_, err := io.ReadFull(r, record)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		w := binary.BigEndian.Uint32(record)
+		require.Less(t, int(w), writers)
+		require.Equal(t, next[w], binary.BigEndian.Uint32(record[4:]))
+		next[w]++
+	}
+	for w := range next {
+		require.Equal(t, uint32(n), next[w])
+	}
+}
+
+func TestRing_Park(t *testing.T) {
+	data := make([]byte, 1<<18)
+	_, _ = rand.Read(data)
+
+	// parked readers and writers are signaled by each other, and once closed
+	for _, opts := range [][]options.Option[Options]{{}, {WithMultiProducer()}} {
+		r := New(append(opts, WithSize(256), WithIdleStrategy(idle.Park))...)
+		go func() {
+			// uses Reserve/Commit with a single producer
+			_, err := io.Copy(r, bytes.NewReader(data))
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+		}()
+		var out bytes.Buffer
+		// uses Peek/Consume
+		_, err := io.Copy(&out, r)
+		require.NoError(t, err)
+		require.Equal(t, data, out.Bytes())
+	}
+
+	r := New(WithSize(8), WithIdleStrategy(idle.Park))
+	go func() {
+		for i := 0; i < 100; i++ {
+			_, err := r.Write([]byte("0123456789"))
+			require.NoError(t, err)
+		}
+		require.NoError(t, r.Close())
+	}()
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Len(t, out, 1000)
+}
@@ -0,0 +1,73 @@
+package clock
+
+import (
+	"context"
+	"time"
+)
+
+// Clock provides time related functionality, it can be injected to control time in tests.
+type Clock interface {
+	Now() time.Time
+	Since(time.Time) time.Duration
+	Sleep(time.Duration)
+	After(time.Duration) <-chan time.Time
+	NewTicker(time.Duration) Ticker
+	AfterFunc(time.Duration, func()) Timer
+	// WithTimeout returns a context that is done once the given duration elapses.
+	WithTimeout(context.Context, time.Duration) (context.Context, context.CancelFunc)
+}
+
+// Ticker delivers ticks at intervals.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Timer is a scheduled function call.
+type Timer interface {
+	// Stop prevents the timer from firing, returns false if it already fired or stopped.
+	Stop() bool
+}
+
+// New returns a clock that is based on the time package.
+func New() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Since(t time.Time) time.Duration {
+	return time.Since(t)
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return &realTicker{time.NewTicker(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+func (realClock) WithTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, d)
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t *realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
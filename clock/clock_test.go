@@ -0,0 +1,31 @@
+package clock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClock(t *testing.T) {
+	c := New()
+	start := c.Now()
+	c.Sleep(time.Millisecond)
+	require.GreaterOrEqual(t, c.Since(start), time.Millisecond)
+
+	fired := make(chan struct{})
+	c.AfterFunc(time.Millisecond, func() {
+		close(fired)
+	})
+	<-fired
+	<-c.After(time.Millisecond)
+	ticker := c.NewTicker(time.Millisecond)
+	<-ticker.C()
+	ticker.Stop()
+
+	ctx, cancel := c.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	require.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+}
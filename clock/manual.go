@@ -0,0 +1,226 @@
+package clock
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Manual is a clock that moves only when it is advanced, it is meant to be used in tests.
+// Timer functions are called on the goroutine that advances the clock.
+type Manual struct {
+	lock    sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*waiter
+}
+
+// waiter is a sleeper, timer or ticker that waits for the clock to reach some deadline
+type waiter struct {
+	deadline time.Time
+	// period is set for tickers
+	period time.Duration
+	ch     chan time.Time
+	fn     func()
+	clock  *Manual
+}
+
+// NewManual creates a manual clock, starting at the given time.
+func NewManual(t time.Time) *Manual {
+	m := &Manual{now: t}
+	m.cond = sync.NewCond(&m.lock)
+	return m
+}
+
+func (m *Manual) Now() time.Time {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.now
+}
+
+func (m *Manual) Since(t time.Time) time.Duration {
+	return m.Now().Sub(t)
+}
+
+// Sleep blocks until the clock is advanced by the given duration.
+func (m *Manual) Sleep(d time.Duration) {
+	<-m.After(d)
+}
+
+func (m *Manual) After(d time.Duration) <-chan time.Time {
+	w := &waiter{ch: make(chan time.Time, 1)}
+	m.add(w, d)
+	return w.ch
+}
+
+func (m *Manual) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	w := &waiter{ch: make(chan time.Time, 1), period: d}
+	m.add(w, d)
+	return &manualTicker{w}
+}
+
+func (m *Manual) AfterFunc(d time.Duration, f func()) Timer {
+	w := &waiter{fn: f}
+	m.add(w, d)
+	return w
+}
+
+// WithTimeout returns a context that is done with context.DeadlineExceeded once the clock is advanced
+// by the given duration. As with context.WithDeadline, an earlier deadline of the parent is kept.
+func (m *Manual) WithTimeout(parent context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+	deadline := m.Now().Add(d)
+	if cur, ok := parent.Deadline(); ok && cur.Before(deadline) {
+		// the parent is done before this deadline
+		return &deadlineCtx{Context: ctx, deadline: cur}, func() {
+			cancel(context.Canceled)
+		}
+	}
+	t := m.AfterFunc(d, func() {
+		cancel(context.DeadlineExceeded)
+	})
+	return &deadlineCtx{Context: ctx, deadline: deadline}, func() {
+		t.Stop()
+		cancel(context.Canceled)
+	}
+}
+
+// Advance moves the clock forward, and fires all the waiters that their deadline has passed, by order.
+func (m *Manual) Advance(d time.Duration) {
+	m.Set(m.Now().Add(d))
+}
+
+// Set moves the clock to the given time, and fires all the waiters that their deadline has passed, by order.
+func (m *Manual) Set(t time.Time) {
+	for {
+		m.lock.Lock()
+		if len(m.waiters) == 0 || m.waiters[0].deadline.After(t) {
+			if t.After(m.now) {
+				m.now = t
+			}
+			m.lock.Unlock()
+			return
+		}
+		w := m.waiters[0]
+		m.waiters = m.waiters[1:]
+		if w.deadline.After(m.now) {
+			m.now = w.deadline
+		}
+		now := m.now
+		if w.period > 0 {
+			w.deadline = w.deadline.Add(w.period)
+			m.insert(w)
+		}
+		m.lock.Unlock()
+
+		w.fire(now)
+	}
+}
+
+// Waiters returns the number of sleepers, timers and tickers that are waiting for the clock.
+func (m *Manual) Waiters() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return len(m.waiters)
+}
+
+// BlockUntil blocks until there are at least n sleepers, timers or tickers that are waiting for the clock.
+func (m *Manual) BlockUntil(n int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for len(m.waiters) < n {
+		m.cond.Wait()
+	}
+}
+
+func (m *Manual) add(w *waiter, d time.Duration) {
+	w.clock = m
+	m.lock.Lock()
+	w.deadline = m.now.Add(d)
+	if d <= 0 && w.period == 0 {
+		now := m.now
+		m.lock.Unlock()
+		w.fire(now)
+		return
+	}
+	m.insert(w)
+	m.cond.Broadcast()
+	m.lock.Unlock()
+}
+
+// insert adds the waiter while keeping the waiters sorted by deadline, assumes the lock is held
+func (m *Manual) insert(w *waiter) {
+	i := sort.Search(len(m.waiters), func(i int) bool {
+		return m.waiters[i].deadline.After(w.deadline)
+	})
+	m.waiters = append(m.waiters, nil)
+	copy(m.waiters[i+1:], m.waiters[i:])
+	m.waiters[i] = w
+}
+
+// remove removes the waiter, returns false if it was not found
+func (m *Manual) remove(w *waiter) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for i, other := range m.waiters {
+		if other == w {
+			m.waiters = append(m.waiters[:i], m.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (w *waiter) fire(now time.Time) {
+	if w.fn != nil {
+		w.fn()
+		return
+	}
+	select {
+	case w.ch <- now:
+	default:
+	}
+}
+
+func (w *waiter) Stop() bool {
+	return w.clock.remove(w)
+}
+
+type manualTicker struct {
+	*waiter
+}
+
+func (t *manualTicker) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *manualTicker) Stop() {
+	t.waiter.Stop()
+}
+
+// deadlineCtx reports context.DeadlineExceeded once it was canceled due to its deadline.
+type deadlineCtx struct {
+	context.Context
+	deadline time.Time
+}
+
+func (ctx *deadlineCtx) Deadline() (time.Time, bool) {
+	return ctx.deadline, true
+}
+
+func (ctx *deadlineCtx) Err() error {
+	err := ctx.Context.Err()
+	if err != nil && errors.Is(context.Cause(ctx.Context), context.DeadlineExceeded) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
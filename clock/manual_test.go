@@ -0,0 +1,113 @@
+package clock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestManual_Sleep(t *testing.T) {
+	start := time.Unix(1000, 0)
+	m := NewManual(start)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Sleep(time.Second)
+	}()
+	m.BlockUntil(1)
+	m.Advance(time.Second - time.Nanosecond)
+	select {
+	case <-done:
+		require.FailNow(t, "woke up too early")
+	case <-time.After(time.Millisecond * 10):
+	}
+	m.Advance(time.Nanosecond)
+	<-done
+	require.Equal(t, start.Add(time.Second), m.Now())
+	require.Equal(t, time.Second, m.Since(start))
+	require.Equal(t, 0, m.Waiters())
+}
+
+func TestManual_AfterFunc(t *testing.T) {
+	m := NewManual(time.Unix(1000, 0))
+
+	var order []int
+	m.AfterFunc(time.Second*3, func() {
+		order = append(order, 3)
+	})
+	m.AfterFunc(time.Second, func() {
+		order = append(order, 1)
+	})
+	stopped := m.AfterFunc(time.Second*2, func() {
+		order = append(order, 2)
+	})
+	m.AfterFunc(0, func() {
+		order = append(order, 0)
+	})
+	require.True(t, stopped.Stop())
+	require.False(t, stopped.Stop())
+
+	m.Advance(time.Second * 5)
+	require.Equal(t, []int{0, 1, 3}, order)
+}
+
+func TestManual_Ticker(t *testing.T) {
+	start := time.Unix(1000, 0)
+	m := NewManual(start)
+
+	ticker := m.NewTicker(time.Second)
+	for i := 1; i <= 3; i++ {
+		m.Advance(time.Second)
+		require.Equal(t, start.Add(time.Second*time.Duration(i)), <-ticker.C())
+	}
+	// ticks are dropped when the reader is slow
+	m.Advance(time.Second * 3)
+	require.Equal(t, start.Add(time.Second*4), <-ticker.C())
+	require.Len(t, ticker.C(), 0)
+
+	ticker.Stop()
+	m.Advance(time.Second)
+	require.Len(t, ticker.C(), 0)
+}
+
+func TestManual_WithTimeout(t *testing.T) {
+	m := NewManual(time.Unix(1000, 0))
+
+	ctx, cancel := m.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	require.Equal(t, time.Unix(1001, 0), deadline)
+	require.NoError(t, ctx.Err())
+	m.Advance(time.Second)
+	<-ctx.Done()
+	require.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+
+	ctx, cancel = m.WithTimeout(context.Background(), time.Second)
+	cancel()
+	<-ctx.Done()
+	require.ErrorIs(t, ctx.Err(), context.Canceled)
+	require.Equal(t, 0, m.Waiters())
+
+	pctx, pcancel := context.WithCancel(context.Background())
+	ctx, cancel = m.WithTimeout(pctx, time.Second)
+	defer cancel()
+	pcancel()
+	<-ctx.Done()
+	require.ErrorIs(t, ctx.Err(), context.Canceled)
+
+	// the earlier deadline of the parent is kept
+	pctx, pcancel = m.WithTimeout(context.Background(), time.Second)
+	defer pcancel()
+	ctx, cancel = m.WithTimeout(pctx, time.Minute)
+	defer cancel()
+	deadline, ok = ctx.Deadline()
+	require.True(t, ok)
+	require.Equal(t, m.Now().Add(time.Second), deadline)
+	m.Advance(time.Second)
+	<-ctx.Done()
+	require.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+}
@@ -13,6 +13,9 @@ type Options struct {
 	// override is a flag that determines whether the data source will allow overriding records or not.
 	// NOTE: applicable only for ring buffer
 	override bool
+	// onEvict is a func(T) that is called with overridden records.
+	// NOTE: applicable only for ring buffer
+	onEvict any
 }
 
 // Capacity returns the capacity config, thread safe
@@ -35,3 +38,16 @@ func WithOverride(o bool) options.Option[Options] {
 		opts.override = o
 	}
 }
+
+// WithOnEvict sets a callback that is called with every record that was overridden,
+// the callback must accept the value type of the data structure, otherwise creating the data structure panics.
+func WithOnEvict[T any](f func(T)) options.Option[Options] {
+	return func(opts *Options) {
+		opts.onEvict = f
+	}
+}
+
+// OnEvict returns the eviction callback, a func(T) or nil
+func (o *Options) OnEvict() any {
+	return o.onEvict
+}
@@ -0,0 +1,313 @@
+package disruptor
+
+import (
+	"context"
+	"errors"
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/amirylm/go-options"
+	"github.com/amirylm/lockfree/idle"
+	"github.com/amirylm/lockfree/internal/pad"
+)
+
+var (
+	// ErrStarted is returned when adding handlers after the disruptor was started
+	ErrStarted = errors.New("disruptor already started")
+)
+
+// Handler is called for every entry of the ring, in sequence order.
+// endOfBatch is true for the last entry that is currently available,
+// which allows handlers to accumulate work and flush it once per batch.
+// NOTE: the entry is owned by the ring, and must not be retained after the handler returns.
+type Handler[T any] func(entry *T, seq int64, endOfBatch bool)
+
+type Options struct {
+	size         int
+	waitStrategy func() idle.Strategy
+}
+
+// WithSize sets the size of the ring, rounded up to a power of 2. Defaults to 1024.
+func WithSize(n int) options.Option[Options] {
+	return func(o *Options) {
+		o.size = n
+	}
+}
+
+// WithWaitStrategy sets the strategy that consumers are using while waiting for entries, defaults to idle.Yield.
+// Each consumer gets its own strategy.
+func WithWaitStrategy(f func() idle.Strategy) options.Option[Options] {
+	return func(o *Options) {
+		o.waitStrategy = f
+	}
+}
+
+// Disruptor is a pre-allocated ring buffer, where multiple consumers read every entry at their own cursor.
+// Producers claim a sequence, write the entry in place and then publish it.
+// Consumers are organized in groups, where the consumers of a group run in parallel,
+// and a dependent group processes an entry only after all consumers of its upstream groups processed it.
+type Disruptor[T any] struct {
+	entries []T
+	// published holds the sequence that was published in each slot
+	published []atomic.Int64
+	mask      int64
+
+	_ pad.CacheLine
+	// claimed is the highest claimed sequence
+	claimed atomic.Int64
+	_       pad.CacheLine
+	// gatingCache is the last known lowest cursor of the gating consumers
+	gatingCache atomic.Int64
+	_           pad.CacheLine
+
+	consumers []*consumer[T]
+	// gating are the consumers that no other consumers depend on, producers can't overrun them
+	gating []*consumer[T]
+
+	waitStrategy func() idle.Strategy
+	started      atomic.Bool
+}
+
+// New creates a new disruptor
+func New[T any](opts ...options.Option[Options]) *Disruptor[T] {
+	o := options.Apply(nil, opts...)
+	if o.size == 0 {
+		o.size = 1024
+	}
+	if o.waitStrategy == nil {
+		o.waitStrategy = idle.Yield
+	}
+	size := 1
+	for size < o.size {
+		size <<= 1
+	}
+
+	d := &Disruptor[T]{
+		entries:      make([]T, size),
+		published:    make([]atomic.Int64, size),
+		mask:         int64(size - 1),
+		waitStrategy: o.waitStrategy,
+	}
+	for i := range d.published {
+		d.published[i].Store(-1)
+	}
+	d.claimed.Store(-1)
+	d.gatingCache.Store(-1)
+	return d
+}
+
+// Group is a set of consumers that are processing entries in parallel
+type Group[T any] struct {
+	d         *Disruptor[T]
+	consumers []*consumer[T]
+}
+
+type consumer[T any] struct {
+	// cursor is padded as consumers are allocated together, and polled by producers and dependents
+	_       pad.CacheLine
+	cursor  atomic.Int64
+	_       pad.CacheLine
+	handler Handler[T]
+	// upstream are the consumers that must process an entry before this consumer,
+	// empty for consumers that are reading published entries
+	upstream   []*consumer[T]
+	dependents []*consumer[T]
+	wait       idle.Strategy
+}
+
+// Handle adds a group of consumers that are reading published entries.
+// NOTE: consumers must be added before producing entries.
+// Returns ErrStarted if the disruptor was already started.
+func (d *Disruptor[T]) Handle(handlers ...Handler[T]) (*Group[T], error) {
+	return d.group(nil, handlers...)
+}
+
+// Then adds a group of consumers that are processing entries after all consumers of this group.
+// Returns ErrStarted if the disruptor was already started.
+func (g *Group[T]) Then(handlers ...Handler[T]) (*Group[T], error) {
+	return g.d.group(g.consumers, handlers...)
+}
+
+// Barrier is a set of groups that consumers can depend on, see After.
+type Barrier[T any] struct {
+	d        *Disruptor[T]
+	upstream []*consumer[T]
+}
+
+// After returns a barrier of the given groups, which allows to build dependency graphs,
+// e.g. joining parallel groups with d.After(b, c).Handle(...).
+// NOTE: the groups must belong to this disruptor.
+func (d *Disruptor[T]) After(groups ...*Group[T]) *Barrier[T] {
+	var upstream []*consumer[T]
+	for _, g := range groups {
+		upstream = append(upstream, g.consumers...)
+	}
+	return &Barrier[T]{d: d, upstream: upstream}
+}
+
+// Handle adds a group of consumers that are processing entries after all consumers of the barrier groups.
+// Returns ErrStarted if the disruptor was already started.
+func (b *Barrier[T]) Handle(handlers ...Handler[T]) (*Group[T], error) {
+	return b.d.group(b.upstream, handlers...)
+}
+
+func (d *Disruptor[T]) group(upstream []*consumer[T], handlers ...Handler[T]) (*Group[T], error) {
+	if d.started.Load() {
+		return nil, ErrStarted
+	}
+	g := &Group[T]{d: d}
+	for _, h := range handlers {
+		c := &consumer[T]{
+			handler:  h,
+			upstream: upstream,
+			wait:     d.waitStrategy(),
+		}
+		c.cursor.Store(-1)
+		for _, u := range upstream {
+			u.dependents = append(u.dependents, c)
+		}
+		g.consumers = append(g.consumers, c)
+	}
+	d.consumers = append(d.consumers, g.consumers...)
+	d.gating = d.gating[:0]
+	for _, c := range d.consumers {
+		if len(c.dependents) == 0 {
+			d.gating = append(d.gating, c)
+		}
+	}
+	return g, nil
+}
+
+// Start starts the consumers, and blocks until the context is done.
+func (d *Disruptor[T]) Start(ctx context.Context) error {
+	if !d.started.CompareAndSwap(false, true) {
+		return ErrStarted
+	}
+	var wg sync.WaitGroup
+	for _, c := range d.consumers {
+		wg.Add(1)
+		go func(c *consumer[T]) {
+			defer wg.Done()
+			d.consume(ctx, c)
+		}(c)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// Next claims the next sequence, and waits while the ring is full.
+// The claimed sequence must be published, otherwise consumers will stall.
+func (d *Disruptor[T]) Next(ctx context.Context) (int64, error) {
+	for {
+		seq, ok := d.TryNext()
+		if ok {
+			return seq, nil
+		}
+		if ctx.Err() != nil {
+			return -1, ctx.Err()
+		}
+		runtime.Gosched()
+	}
+}
+
+// TryNext claims the next sequence, returns false if the ring is full.
+func (d *Disruptor[T]) TryNext() (int64, bool) {
+	for {
+		current := d.claimed.Load()
+		next := current + 1
+		wrap := next - int64(len(d.entries))
+		if wrap > d.gatingCache.Load() {
+			lowest := d.lowestGating(current)
+			d.gatingCache.Store(lowest)
+			if wrap > lowest {
+				return -1, false
+			}
+		}
+		if d.claimed.CompareAndSwap(current, next) {
+			return next, true
+		}
+	}
+}
+
+// Get returns the entry of the given sequence, to be written by the producer before publishing.
+func (d *Disruptor[T]) Get(seq int64) *T {
+	return &d.entries[seq&d.mask]
+}
+
+// Publish makes the entry of the given sequence available for consumers.
+func (d *Disruptor[T]) Publish(seq int64) {
+	d.published[seq&d.mask].Store(seq)
+	for _, c := range d.consumers {
+		if len(c.upstream) == 0 {
+			c.wait.Signal()
+		}
+	}
+}
+
+// PublishEvent claims the next sequence, fills the entry with the given function and publishes it.
+func (d *Disruptor[T]) PublishEvent(ctx context.Context, fill func(entry *T)) error {
+	seq, err := d.Next(ctx)
+	if err != nil {
+		return err
+	}
+	fill(d.Get(seq))
+	d.Publish(seq)
+	return nil
+}
+
+// lowestGating returns the lowest cursor of the gating consumers, or the given default if there are none.
+func (d *Disruptor[T]) lowestGating(def int64) int64 {
+	if len(d.gating) == 0 {
+		return def
+	}
+	lowest := int64(math.MaxInt64)
+	for _, c := range d.gating {
+		lowest = min(lowest, c.cursor.Load())
+	}
+	return lowest
+}
+
+// available returns the highest sequence that is available for the consumer,
+// which is lower than next in case there are no available entries.
+func (d *Disruptor[T]) available(c *consumer[T], next int64) int64 {
+	if len(c.upstream) > 0 {
+		lowest := int64(math.MaxInt64)
+		for _, u := range c.upstream {
+			lowest = min(lowest, u.cursor.Load())
+		}
+		return lowest
+	}
+	// entries are published out of order by multiple producers, therefore only a contiguous range is available
+	claimed := d.claimed.Load()
+	seq := next
+	for ; seq <= claimed; seq++ {
+		if d.published[seq&d.mask].Load() != seq {
+			break
+		}
+	}
+	return seq - 1
+}
+
+func (d *Disruptor[T]) consume(ctx context.Context, c *consumer[T]) {
+	next := c.cursor.Load() + 1
+	idleCount := 0
+	for ctx.Err() == nil {
+		avail := d.available(c, next)
+		if avail < next {
+			idleCount++
+			c.wait.Idle(ctx, idleCount)
+			continue
+		}
+		idleCount = 0
+		for seq := next; seq <= avail; seq++ {
+			c.handler(&d.entries[seq&d.mask], seq, seq == avail)
+		}
+		c.cursor.Store(avail)
+		next = avail + 1
+		for _, dep := range c.dependents {
+			dep.wait.Signal()
+		}
+	}
+}
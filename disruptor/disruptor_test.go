@@ -0,0 +1,193 @@
+package disruptor
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/amirylm/go-options"
+	"github.com/amirylm/lockfree/idle"
+	"github.com/stretchr/testify/require"
+)
+
+type entry struct {
+	value   int64
+	doubled int64
+}
+
+func TestDisruptor(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int
+		producers int
+		n         int
+		wait      func() idle.Strategy
+	}{
+		{"single producer", 16, 1, 1000, nil},
+		{"multi producers", 64, 4, 1000, nil},
+		{"park", 8, 2, 500, idle.Park},
+		{"backoff", 1024, 1, 1000, func() idle.Strategy { return idle.Backoff() }},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			opts := []options.Option[Options]{WithSize(tc.size)}
+			if tc.wait != nil {
+				opts = append(opts, WithWaitStrategy(tc.wait))
+			}
+			d := New[entry](opts...)
+			total := int64(tc.producers * tc.n)
+
+			// two parallel consumers that read every entry, while one of them is enriching entries
+			var sums [2]atomic.Int64
+			var batches atomic.Int64
+			g, err := d.Handle(func(e *entry, _ int64, _ bool) {
+				e.doubled = e.value * 2
+				sums[0].Add(e.value)
+			}, func(e *entry, _ int64, endOfBatch bool) {
+				sums[1].Add(e.value)
+				if endOfBatch {
+					batches.Add(1)
+				}
+			})
+			require.NoError(t, err)
+			// dependent consumer sees the changes of upstream consumers
+			var doubled, seen, unordered atomic.Int64
+			lastSeq := int64(-1)
+			_, err = g.Then(func(e *entry, seq int64, _ bool) {
+				if seq != lastSeq+1 {
+					unordered.Add(1)
+				}
+				lastSeq = seq
+				doubled.Add(e.doubled)
+				seen.Add(1)
+			})
+			require.NoError(t, err)
+
+			go func() {
+				_ = d.Start(ctx)
+			}()
+
+			var wg sync.WaitGroup
+			for p := 0; p < tc.producers; p++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 1; i <= tc.n; i++ {
+						v := int64(i)
+						if err := d.PublishEvent(ctx, func(e *entry) {
+							e.value = v
+						}); err != nil {
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			for seen.Load() < total && ctx.Err() == nil {
+				time.Sleep(time.Millisecond)
+			}
+
+			expected := int64(tc.producers * tc.n * (tc.n + 1) / 2)
+			require.Equal(t, total, seen.Load())
+			require.Zero(t, unordered.Load())
+			require.Equal(t, expected, sums[0].Load())
+			require.Equal(t, expected, sums[1].Load())
+			require.Equal(t, expected*2, doubled.Load())
+			require.Greater(t, batches.Load(), int64(0))
+			require.LessOrEqual(t, batches.Load(), total)
+		})
+	}
+}
+
+func TestDisruptor_Gating(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	d := New[int](WithSize(4))
+	release := make(chan struct{})
+	var handled atomic.Int32
+	_, err := d.Handle(func(*int, int64, bool) {
+		<-release
+		handled.Add(1)
+	})
+	require.NoError(t, err)
+	go func() {
+		_ = d.Start(ctx)
+	}()
+
+	// the ring is full once all slots were claimed and not consumed
+	for i := 0; i < 4; i++ {
+		seq, ok := d.TryNext()
+		require.True(t, ok)
+		d.Publish(seq)
+	}
+	_, ok := d.TryNext()
+	require.False(t, ok)
+
+	close(release)
+	seq, err := d.Next(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), seq)
+	d.Publish(seq)
+	for handled.Load() < 5 && ctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	require.Equal(t, int32(5), handled.Load())
+
+	require.ErrorIs(t, d.Start(ctx), ErrStarted)
+	_, err = d.Handle(func(*int, int64, bool) {})
+	require.ErrorIs(t, err, ErrStarted)
+}
+
+func TestDisruptor_Diamond(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	type diamond struct {
+		value, doubled, tripled int64
+	}
+	d := New[diamond](WithSize(16))
+	// a -> (b, c) -> join
+	a, err := d.Handle(func(e *diamond, seq int64, _ bool) {
+		e.value = seq
+	})
+	require.NoError(t, err)
+	b, err := a.Then(func(e *diamond, _ int64, _ bool) {
+		e.doubled = e.value * 2
+	})
+	require.NoError(t, err)
+	c, err := a.Then(func(e *diamond, _ int64, _ bool) {
+		e.tripled = e.value * 3
+	})
+	require.NoError(t, err)
+	var seen, mismatched atomic.Int64
+	_, err = d.After(b, c).Handle(func(e *diamond, seq int64, _ bool) {
+		if e.doubled+e.tripled != seq*5 {
+			mismatched.Add(1)
+		}
+		seen.Add(1)
+	})
+	require.NoError(t, err)
+
+	go func() {
+		_ = d.Start(ctx)
+	}()
+
+	n := int64(1000)
+	for i := int64(0); i < n; i++ {
+		require.NoError(t, d.PublishEvent(ctx, func(e *diamond) {
+			*e = diamond{}
+		}))
+	}
+	for seen.Load() < n && ctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	require.Equal(t, n, seen.Load())
+	require.Zero(t, mismatched.Load())
+}
@@ -18,13 +18,14 @@ func main() {
 	}, func(h hash.Hash, data []byte) [32]byte {
 		var b [32]byte
 		_, err := h.Write(data)
-		defer h.Reset()
 		if err != nil {
 			return b
 		}
 		h.Sum(b[:0])
 		return b
-	})
+	}, pool.OnPut(func(h hash.Hash) {
+		h.Reset()
+	}))
 
 	n := 10
 	done := make(chan struct{})
@@ -0,0 +1,252 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/amirylm/go-options"
+	"github.com/amirylm/lockfree/core"
+	"github.com/amirylm/lockfree/idle"
+	"github.com/amirylm/lockfree/queue"
+)
+
+var (
+	// ErrShutdown is returned when submitting tasks once shutdown started
+	ErrShutdown = errors.New("executor is shutting down")
+	// ErrPanic is returned by SubmitWait when the task panicked
+	ErrPanic = errors.New("task panicked")
+)
+
+// Executor runs tasks on a fixed (but resizable) number of goroutines, fed by a lock-free queue.
+type Executor interface {
+	// Close stops the workers without waiting for queued tasks
+	io.Closer
+	// Submit adds a task to the queue, and waits while the queue is full until the context is done.
+	// Returns ErrShutdown once shutdown started, or once the executor is closed.
+	Submit(ctx context.Context, task func()) error
+	// Resize changes the number of workers, extra workers exit once they complete their current task.
+	Resize(workers int)
+	// Workers returns the number of running workers
+	Workers() int
+	// Shutdown stops accepting new tasks, and waits for queued and running tasks to complete.
+	Shutdown(ctx context.Context) error
+}
+
+type Options struct {
+	queue        core.Queue[func()]
+	workers      int
+	idleStrategy func() idle.Strategy
+	panicHandler func(any)
+}
+
+// WithQueue sets the task queue, defaults to queue.New with capacity of 1024.
+func WithQueue(q core.Queue[func()]) options.Option[Options] {
+	return func(o *Options) {
+		o.queue = q
+	}
+}
+
+// WithWorkers sets the initial number of workers, defaults to GOMAXPROCS.
+func WithWorkers(n int) options.Option[Options] {
+	return func(o *Options) {
+		o.workers = n
+	}
+}
+
+// WithIdleStrategy sets the idle strategy of workers when the queue is empty, defaults to idle.Backoff.
+// An idle worker is signaled on submit, and all workers are signaled on resize.
+func WithIdleStrategy(f func() idle.Strategy) options.Option[Options] {
+	return func(o *Options) {
+		o.idleStrategy = f
+	}
+}
+
+// WithPanicHandler sets a hook that is called with the recovered value whenever a task panics.
+func WithPanicHandler(f func(recovered any)) options.Option[Options] {
+	return func(o *Options) {
+		o.panicHandler = f
+	}
+}
+
+type executor struct {
+	q            core.Queue[func()]
+	idleStrategy func() idle.Strategy
+	panicHandler func(any)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// target is the desired number of workers, while running is the actual number
+	target, running atomic.Int32
+	// pending is the number of tasks that were submitted and not completed
+	pending  atomic.Int64
+	draining atomic.Bool
+	// waiters holds the idle strategies of the running workers
+	waiters idle.Waiters
+}
+
+// New creates a new executor and starts its workers
+func New(opts ...options.Option[Options]) Executor {
+	o := options.Apply(nil, opts...)
+	if o.queue == nil {
+		o.queue = queue.New[func()](core.WithCapacity(1024))
+	}
+	if o.workers == 0 {
+		o.workers = runtime.GOMAXPROCS(0)
+	}
+	if o.idleStrategy == nil {
+		o.idleStrategy = func() idle.Strategy {
+			return idle.Backoff()
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &executor{
+		q:            o.queue,
+		idleStrategy: o.idleStrategy,
+		panicHandler: o.panicHandler,
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+	e.Resize(o.workers)
+	return e
+}
+
+func (e *executor) Submit(ctx context.Context, task func()) error {
+	if e.stopped() {
+		return ErrShutdown
+	}
+	e.pending.Add(1)
+	for !e.q.Enqueue(task) {
+		if err := ctx.Err(); err != nil {
+			e.pending.Add(-1)
+			return err
+		}
+		if e.stopped() {
+			e.pending.Add(-1)
+			return ErrShutdown
+		}
+		runtime.Gosched()
+	}
+	e.waiters.SignalOne()
+	return nil
+}
+
+// stopped returns true once shutdown started or the executor was closed
+func (e *executor) stopped() bool {
+	return e.draining.Load() || e.ctx.Err() != nil
+}
+
+func (e *executor) Resize(workers int) {
+	if workers < 0 {
+		workers = 0
+	}
+	e.target.Store(int32(workers))
+	for {
+		running := e.running.Load()
+		if running >= int32(workers) || e.ctx.Err() != nil {
+			// waking up idle workers, so extra workers exit
+			e.waiters.Signal()
+			return
+		}
+		if e.running.CompareAndSwap(running, running+1) {
+			go e.work()
+		}
+	}
+}
+
+func (e *executor) Workers() int {
+	return int(e.running.Load())
+}
+
+func (e *executor) Shutdown(ctx context.Context) error {
+	e.draining.Store(true)
+	defer func() {
+		_ = e.Close()
+	}()
+	for e.pending.Load() > 0 {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%d pending tasks: %w", e.pending.Load(), ctx.Err())
+		}
+		runtime.Gosched()
+	}
+	return nil
+}
+
+func (e *executor) Close() error {
+	e.cancel()
+	return nil
+}
+
+// work runs tasks until the context is done or there are too many workers
+func (e *executor) work() {
+	w := e.waiters.Add(e.idleStrategy())
+	defer e.waiters.Remove(w)
+	idleCount := 0
+	for e.ctx.Err() == nil {
+		if running := e.running.Load(); running > e.target.Load() {
+			if e.running.CompareAndSwap(running, running-1) {
+				return
+			}
+			continue
+		}
+		task, ok := e.q.Dequeue()
+		if !ok {
+			idleCount++
+			w.Idle(e.ctx, idleCount, func() bool {
+				task, ok = e.q.Dequeue()
+				return ok
+			})
+			if !ok {
+				continue
+			}
+		}
+		idleCount = 0
+		e.run(task)
+	}
+	e.running.Add(-1)
+}
+
+func (e *executor) run(task func()) {
+	defer e.pending.Add(-1)
+	defer func() {
+		if r := recover(); r != nil && e.panicHandler != nil {
+			e.panicHandler(r)
+		}
+	}()
+	task()
+}
+
+// SubmitWait submits the task and waits for its result.
+// Panics are recovered and returned as an error that wraps ErrPanic.
+func SubmitWait[T any](ctx context.Context, e Executor, task func(context.Context) (T, error)) (T, error) {
+	type result struct {
+		v   T
+		err error
+	}
+	done := make(chan result, 1)
+	err := e.Submit(ctx, func() {
+		var res result
+		defer func() {
+			if r := recover(); r != nil {
+				res.err = fmt.Errorf("%w: %v", ErrPanic, r)
+			}
+			done <- res
+		}()
+		res.v, res.err = task(ctx)
+	})
+	if err != nil {
+		var v T
+		return v, err
+	}
+	select {
+	case res := <-done:
+		return res.v, res.err
+	case <-ctx.Done():
+		var v T
+		return v, ctx.Err()
+	}
+}
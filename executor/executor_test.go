@@ -0,0 +1,232 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/amirylm/lockfree/core"
+	"github.com/amirylm/lockfree/idle"
+	"github.com/amirylm/lockfree/ringbuffer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutor(t *testing.T) {
+	var panics atomic.Int32
+	e := New(
+		WithWorkers(4),
+		WithQueue(ringbuffer.New[func()](core.WithCapacity(64))),
+		WithPanicHandler(func(any) {
+			panics.Add(1)
+		}),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	require.Eventually(t, func() bool {
+		return e.Workers() == 4
+	}, time.Second, time.Millisecond)
+
+	var done atomic.Int32
+	n := 500
+	for i := 0; i < n; i++ {
+		i := i
+		require.NoError(t, e.Submit(ctx, func() {
+			if i%100 == 0 {
+				panic("boom")
+			}
+			done.Add(1)
+		}))
+	}
+
+	res, err := SubmitWait(ctx, e, func(context.Context) (int, error) {
+		return 42, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 42, res)
+
+	errTask := errors.New("task failed")
+	_, err = SubmitWait(ctx, e, func(context.Context) (int, error) {
+		return 0, errTask
+	})
+	require.ErrorIs(t, err, errTask)
+
+	_, err = SubmitWait(ctx, e, func(context.Context) (int, error) {
+		panic("boom")
+	})
+	require.ErrorIs(t, err, ErrPanic)
+
+	require.NoError(t, e.Shutdown(ctx))
+	require.Equal(t, int32(n-5), done.Load())
+	require.Equal(t, int32(5), panics.Load())
+	require.ErrorIs(t, e.Submit(ctx, func() {}), ErrShutdown)
+	require.Eventually(t, func() bool {
+		return e.Workers() == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestExecutor_Resize(t *testing.T) {
+	e := New(WithWorkers(2))
+	defer func() {
+		_ = e.Close()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// blocking all workers, and checking that tasks are running in parallel
+	release := make(chan struct{})
+	var running atomic.Int32
+	block := func() {
+		running.Add(1)
+		<-release
+		running.Add(-1)
+	}
+	e.Resize(8)
+	for i := 0; i < 8; i++ {
+		require.NoError(t, e.Submit(ctx, block))
+	}
+	require.Eventually(t, func() bool {
+		return running.Load() == 8
+	}, time.Second, time.Millisecond)
+
+	e.Resize(1)
+	close(release)
+	require.Eventually(t, func() bool {
+		return e.Workers() == 1
+	}, time.Second, time.Millisecond)
+
+	res, err := SubmitWait(ctx, e, func(context.Context) (string, error) {
+		return "ok", nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "ok", res)
+}
+
+func TestExecutor_ShutdownDeadline(t *testing.T) {
+	e := New(WithWorkers(1))
+	release := make(chan struct{})
+	defer close(release)
+	require.NoError(t, e.Submit(context.Background(), func() {
+		<-release
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	err := e.Shutdown(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Contains(t, err.Error(), "1 pending tasks")
+}
+
+func TestExecutor_BackPressure(t *testing.T) {
+	e := New(WithWorkers(1), WithQueue(ringbuffer.New[func()](core.WithCapacity(1))))
+	release := make(chan struct{})
+	defer func() {
+		close(release)
+		_ = e.Close()
+	}()
+	block := func() {
+		<-release
+	}
+	require.NoError(t, e.Submit(context.Background(), block))
+	// waits for the worker to take the first task, and fills the queue
+	require.NoError(t, e.Submit(context.Background(), block))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	require.ErrorIs(t, e.Submit(ctx, block), context.DeadlineExceeded)
+}
+
+func TestExecutor_SubmitClosed(t *testing.T) {
+	e := New(WithWorkers(1), WithQueue(ringbuffer.New[func()](core.WithCapacity(1))))
+	release := make(chan struct{})
+	defer close(release)
+	block := func() {
+		<-release
+	}
+	require.NoError(t, e.Submit(context.Background(), block))
+	// waits for the worker to take the first task, and fills the queue
+	require.NoError(t, e.Submit(context.Background(), block))
+
+	// submits that are waiting for space in the queue are released once closed
+	errs := make(chan error, 1)
+	go func() {
+		errs <- e.Submit(context.Background(), block)
+	}()
+	require.NoError(t, e.Close())
+	require.ErrorIs(t, <-errs, ErrShutdown)
+	require.ErrorIs(t, e.Submit(context.Background(), block), ErrShutdown)
+}
+
+func TestExecutor_Park(t *testing.T) {
+	e := New(WithWorkers(4), WithIdleStrategy(idle.Park))
+	defer func() {
+		_ = e.Close()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	require.Eventually(t, func() bool {
+		return e.Workers() == 4
+	}, time.Second, time.Millisecond)
+
+	// parked workers are woken up on submit
+	for i := 0; i < 100; i++ {
+		res, err := SubmitWait(ctx, e, func(context.Context) (int, error) {
+			return i, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, i, res)
+	}
+	// and on resize
+	e.Resize(1)
+	require.Eventually(t, func() bool {
+		return e.Workers() == 1
+	}, time.Second, time.Millisecond)
+	require.NoError(t, e.Shutdown(ctx))
+}
+
+// wakeStrategy counts the workers that are parked, and the times they woke up
+type wakeStrategy struct {
+	idle.Strategy
+	parked, woken *atomic.Int32
+}
+
+func (s *wakeStrategy) Idle(ctx context.Context, idleCount int) {
+	s.parked.Add(1)
+	s.Strategy.Idle(ctx, idleCount)
+	s.parked.Add(-1)
+	s.woken.Add(1)
+}
+
+func TestExecutor_SignalOne(t *testing.T) {
+	var parked, woken atomic.Int32
+	e := New(WithWorkers(4), WithIdleStrategy(func() idle.Strategy {
+		return &wakeStrategy{Strategy: idle.Park(), parked: &parked, woken: &woken}
+	}))
+	defer func() {
+		_ = e.Close()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	require.Eventually(t, func() bool {
+		return parked.Load() == 4
+	}, time.Second, time.Millisecond)
+	// letting workers that were signaled on start park again
+	time.Sleep(time.Millisecond * 20)
+
+	for i := 0; i < 10; i++ {
+		require.Eventually(t, func() bool {
+			return parked.Load() == 4
+		}, time.Second, time.Millisecond)
+		before := woken.Load()
+		_, err := SubmitWait(ctx, e, func(context.Context) (int, error) {
+			return i, nil
+		})
+		require.NoError(t, err)
+		// a single parked worker is woken up for the task
+		require.Eventually(t, func() bool {
+			return parked.Load() == 4
+		}, time.Second, time.Millisecond)
+		require.Equal(t, before+1, woken.Load())
+	}
+}
@@ -0,0 +1,154 @@
+package idle
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	"github.com/amirylm/go-options"
+	"github.com/amirylm/lockfree/clock"
+)
+
+// Strategy decides what a polling loop does when it has no work.
+type Strategy interface {
+	// Idle is called when there is no work,
+	// idleCount is the number of consecutive idle iterations, starting at 1.
+	Idle(ctx context.Context, idleCount int)
+	// Signal notifies that there is new work, and wakes up parked loops.
+	Signal()
+}
+
+// BusySpin returns immediately, providing the lowest latency at the cost of a fully used CPU.
+func BusySpin() Strategy {
+	return busySpin{}
+}
+
+type busySpin struct{}
+
+func (busySpin) Idle(context.Context, int) {}
+
+func (busySpin) Signal() {}
+
+// Yield yields the processor, allowing other goroutines to run.
+func Yield() Strategy {
+	return yield{}
+}
+
+type yield struct{}
+
+func (yield) Idle(context.Context, int) {
+	runtime.Gosched()
+}
+
+func (yield) Signal() {}
+
+// BackoffOptions is the configuration of the backoff strategy
+type BackoffOptions struct {
+	spins, yields      int
+	minSleep, maxSleep time.Duration
+	clock              clock.Clock
+}
+
+// WithSpins sets the number of idle iterations that spin before yielding.
+func WithSpins(n int) options.Option[BackoffOptions] {
+	return func(o *BackoffOptions) {
+		o.spins = n
+	}
+}
+
+// WithYields sets the number of idle iterations that yield before sleeping.
+func WithYields(n int) options.Option[BackoffOptions] {
+	return func(o *BackoffOptions) {
+		o.yields = n
+	}
+}
+
+// WithSleep sets the min and max sleep durations, the sleep duration is doubled on each idle iteration.
+func WithSleep(min, max time.Duration) options.Option[BackoffOptions] {
+	return func(o *BackoffOptions) {
+		o.minSleep = min
+		o.maxSleep = max
+	}
+}
+
+// WithClock sets the clock that is used for sleeping.
+func WithClock(c clock.Clock) options.Option[BackoffOptions] {
+	return func(o *BackoffOptions) {
+		o.clock = c
+	}
+}
+
+// Backoff is a progressive strategy that spins, then yields and then sleeps with exponential backoff.
+func Backoff(opts ...options.Option[BackoffOptions]) Strategy {
+	o := options.Apply(nil, opts...)
+	if o.spins == 0 {
+		o.spins = 64
+	}
+	if o.yields == 0 {
+		o.yields = 64
+	}
+	if o.minSleep == 0 {
+		o.minSleep = time.Microsecond * 50
+	}
+	if o.maxSleep == 0 {
+		o.maxSleep = time.Millisecond
+	}
+	if o.clock == nil {
+		o.clock = clock.New()
+	}
+	return &backoff{*o}
+}
+
+type backoff struct {
+	BackoffOptions
+}
+
+func (b *backoff) Idle(ctx context.Context, idleCount int) {
+	switch {
+	case idleCount <= b.spins:
+	case idleCount <= b.spins+b.yields:
+		runtime.Gosched()
+	default:
+		b.clock.Sleep(b.sleep(idleCount - b.spins - b.yields))
+	}
+}
+
+// sleep returns the sleep duration of the given sleeping iteration (starting at 1)
+func (b *backoff) sleep(n int) time.Duration {
+	d := b.minSleep
+	for i := 1; i < n && d < b.maxSleep; i++ {
+		d *= 2
+	}
+	if d > b.maxSleep {
+		d = b.maxSleep
+	}
+	return d
+}
+
+func (b *backoff) Signal() {}
+
+// Park blocks until Signal is called or the context is done.
+// NOTE: a parking strategy must not be shared by multiple loops.
+func Park() Strategy {
+	return &park{
+		signals: make(chan struct{}, 1),
+	}
+}
+
+type park struct {
+	signals chan struct{}
+}
+
+func (p *park) Idle(ctx context.Context, _ int) {
+	select {
+	case <-ctx.Done():
+	case <-p.signals:
+	}
+}
+
+func (p *park) Signal() {
+	select {
+	case p.signals <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,76 @@
+package idle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/amirylm/lockfree/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff(t *testing.T) {
+	clk := clock.NewManual(time.Now())
+	b := Backoff(WithSpins(2), WithYields(2), WithSleep(time.Millisecond, time.Millisecond*4), WithClock(clk))
+
+	// spins and yields don't sleep
+	for i := 1; i <= 4; i++ {
+		b.Idle(context.Background(), i)
+	}
+	require.Equal(t, 0, clk.Waiters())
+
+	tests := []struct {
+		idleCount int
+		sleep     time.Duration
+	}{
+		{5, time.Millisecond},
+		{6, time.Millisecond * 2},
+		{7, time.Millisecond * 4},
+		{20, time.Millisecond * 4},
+	}
+	for _, tc := range tests {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			b.Idle(context.Background(), tc.idleCount)
+		}()
+		clk.BlockUntil(1)
+		clk.Advance(tc.sleep - time.Nanosecond)
+		require.Equal(t, 1, clk.Waiters(), "woke up too early on idle count %d", tc.idleCount)
+		clk.Advance(time.Nanosecond)
+		<-done
+	}
+}
+
+func TestPark(t *testing.T) {
+	p := Park()
+
+	// signals before parking are not lost
+	p.Signal()
+	p.Signal()
+	p.Idle(context.Background(), 1)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Idle(context.Background(), 1)
+	}()
+	select {
+	case <-done:
+		require.FailNow(t, "should be parked")
+	case <-time.After(time.Millisecond * 10):
+	}
+	p.Signal()
+	<-done
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.Idle(ctx, 1)
+}
+
+func TestSpinAndYield(t *testing.T) {
+	for _, s := range []Strategy{BusySpin(), Yield()} {
+		s.Signal()
+		s.Idle(context.Background(), 1)
+	}
+}
@@ -0,0 +1,94 @@
+package idle
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+)
+
+// Waiters holds the strategies of loops that are waiting for work, so they are signaled once there is new work.
+// The registry is copy-on-write, therefore signaling doesn't lock or allocate.
+type Waiters struct {
+	waiters atomic.Pointer[[]*Waiter]
+}
+
+// Waiter is a strategy that was added to Waiters.
+type Waiter struct {
+	s Strategy
+	// idle is set while the loop is idle, so SignalOne wakes up a loop that is actually waiting
+	idle atomic.Bool
+}
+
+// Idle marks the waiter as idle and calls the strategy, unless pending reports that there is new work.
+// Checking for work once the waiter is marked makes sure that work which is added concurrently signals it.
+func (w *Waiter) Idle(ctx context.Context, idleCount int, pending func() bool) {
+	w.idle.Store(true)
+	if !pending() {
+		w.s.Idle(ctx, idleCount)
+	}
+	w.idle.Store(false)
+}
+
+// Add registers the strategy, the returned waiter is used to remove it.
+func (ws *Waiters) Add(s Strategy) *Waiter {
+	w := &Waiter{s: s}
+	ws.update(func(waiters []*Waiter) []*Waiter {
+		return append(waiters, w)
+	})
+	return w
+}
+
+// Remove unregisters the waiter, once its loop stops waiting.
+func (ws *Waiters) Remove(w *Waiter) {
+	ws.update(func(waiters []*Waiter) []*Waiter {
+		return slices.DeleteFunc(waiters, func(current *Waiter) bool {
+			return current == w
+		})
+	})
+}
+
+// Signal wakes up all the waiters.
+func (ws *Waiters) Signal() {
+	if waiters := ws.waiters.Load(); waiters != nil {
+		for _, w := range *waiters {
+			w.s.Signal()
+		}
+	}
+}
+
+// SignalOne wakes up a single idle waiter, see Waiter.Idle.
+// Returns false if there are no idle waiters.
+func (ws *Waiters) SignalOne() bool {
+	if waiters := ws.waiters.Load(); waiters != nil {
+		for _, w := range *waiters {
+			if w.idle.CompareAndSwap(true, false) {
+				w.s.Signal()
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Len returns the number of waiters.
+func (ws *Waiters) Len() int {
+	if waiters := ws.waiters.Load(); waiters != nil {
+		return len(*waiters)
+	}
+	return 0
+}
+
+// update replaces the waiters with a modified copy
+func (ws *Waiters) update(f func([]*Waiter) []*Waiter) {
+	for {
+		current := ws.waiters.Load()
+		var waiters []*Waiter
+		if current != nil {
+			waiters = append(waiters, *current...)
+		}
+		waiters = f(waiters)
+		if ws.waiters.CompareAndSwap(current, &waiters) {
+			return
+		}
+	}
+}
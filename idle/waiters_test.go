@@ -0,0 +1,82 @@
+package idle
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type countStrategy struct {
+	idles, signals atomic.Int32
+}
+
+func (s *countStrategy) Idle(context.Context, int) {
+	s.idles.Add(1)
+}
+
+func (s *countStrategy) Signal() {
+	s.signals.Add(1)
+}
+
+func TestWaiters(t *testing.T) {
+	var ws Waiters
+	ws.Signal()
+
+	s1, s2 := &countStrategy{}, &countStrategy{}
+	w1 := ws.Add(s1)
+	ws.Add(s2)
+	require.Equal(t, 2, ws.Len())
+	ws.Signal()
+	require.Equal(t, int32(1), s1.signals.Load())
+	require.Equal(t, int32(1), s2.signals.Load())
+
+	ws.Remove(w1)
+	require.Equal(t, 1, ws.Len())
+	ws.Signal()
+	require.Equal(t, int32(1), s1.signals.Load(), "removed waiter was signaled")
+	require.Equal(t, int32(2), s2.signals.Load())
+}
+
+func TestWaiters_SignalOne(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	var ws Waiters
+	require.False(t, ws.SignalOne())
+
+	var woken atomic.Int32
+	waiters := []*Waiter{ws.Add(Park()), ws.Add(Park())}
+	for _, w := range waiters {
+		go func(w *Waiter) {
+			w.Idle(ctx, 1, func() bool {
+				return false
+			})
+			woken.Add(1)
+		}(w)
+	}
+	require.Eventually(t, func() bool {
+		return waiters[0].idle.Load() && waiters[1].idle.Load()
+	}, time.Second, time.Millisecond)
+
+	// each signal wakes up a different idle waiter
+	require.True(t, ws.SignalOne())
+	require.Eventually(t, func() bool {
+		return woken.Load() == 1
+	}, time.Second, time.Millisecond)
+	require.True(t, ws.SignalOne())
+	require.Eventually(t, func() bool {
+		return woken.Load() == 2
+	}, time.Second, time.Millisecond)
+	require.False(t, ws.SignalOne())
+
+	// pending work skips idling
+	idled := &countStrategy{}
+	ws.Add(idled).Idle(ctx, 1, func() bool {
+		return true
+	})
+	require.Zero(t, idled.idles.Load())
+	require.False(t, ws.SignalOne())
+}
@@ -0,0 +1,10 @@
+// Package pad provides cache line padding, used to separate hot fields that are written by different goroutines.
+package pad
+
+// CacheLineSize is the assumed size of a cache line, 64 bytes on most platforms (amd64, arm64).
+// Adjacent lines might be prefetched together, however padding to a single line is usually enough.
+const CacheLineSize = 64
+
+// CacheLine is placed between hot fields to keep them on separate cache lines,
+// so writes to one field are not invalidating the line of the other (false sharing).
+type CacheLine [CacheLineSize]byte
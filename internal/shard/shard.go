@@ -0,0 +1,110 @@
+package shard
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/amirylm/lockfree/core"
+)
+
+var (
+	nextHint atomic.Uint32
+	// hints relies on the per-P caches of sync.Pool, a goroutine usually gets the hint of its current P
+	hints = sync.Pool{New: func() any {
+		h := nextHint.Add(1) - 1
+		return &h
+	}}
+)
+
+// Hint returns a cheap hint of the current processor, used to pick a shard.
+// Goroutines that are running on the same P usually get the same hint, while it is not guaranteed.
+func Hint() int {
+	h := hints.Get().(*uint32)
+	v := *h
+	hints.Put(h)
+	return int(v)
+}
+
+// Count returns the default number of shards
+func Count() int {
+	return runtime.GOMAXPROCS(0)
+}
+
+// Queue is a sharded queue, elements are added to the shard of the current processor,
+// and taken from it first while stealing from other shards on a miss.
+// FIFO order is kept only within a shard.
+type Queue[T any] struct {
+	shards []core.Queue[T]
+}
+
+// NewQueue creates a sharded queue with n shards, using the given constructor for each shard.
+func NewQueue[T any](n int, newShard func() core.Queue[T]) *Queue[T] {
+	if n <= 0 {
+		n = Count()
+	}
+	shards := make([]core.Queue[T], n)
+	for i := range shards {
+		shards[i] = newShard()
+	}
+	return &Queue[T]{shards: shards}
+}
+
+// Enqueue adds the value to the local shard, or to other shards in case it is full.
+func (q *Queue[T]) Enqueue(v T) bool {
+	n := len(q.shards)
+	start := Hint() % n
+	for i := 0; i < n; i++ {
+		if q.shards[(start+i)%n].Enqueue(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Dequeue takes a value from the local shard, or steals from other shards in case it is empty.
+func (q *Queue[T]) Dequeue() (T, bool) {
+	n := len(q.shards)
+	start := Hint() % n
+	for i := 0; i < n; i++ {
+		if v, ok := q.shards[(start+i)%n].Dequeue(); ok {
+			return v, true
+		}
+	}
+	var v T
+	return v, false
+}
+
+func (q *Queue[T]) Size() int {
+	size := 0
+	for _, s := range q.shards {
+		size += s.Size()
+	}
+	return size
+}
+
+func (q *Queue[T]) Empty() bool {
+	for _, s := range q.shards {
+		if !s.Empty() {
+			return false
+		}
+	}
+	return true
+}
+
+func (q *Queue[T]) Full() bool {
+	for _, s := range q.shards {
+		if !s.Full() {
+			return false
+		}
+	}
+	return true
+}
+
+// Capacity returns the capacity of each shard, given the total capacity
+func Capacity(total, shards int) int {
+	if shards <= 0 {
+		shards = Count()
+	}
+	return (total + shards - 1) / shards
+}
@@ -0,0 +1,102 @@
+package shard
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/amirylm/lockfree/core"
+	"github.com/amirylm/lockfree/stack"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHint(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		require.GreaterOrEqual(t, Hint(), 0)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	q := NewQueue(4, func() core.Queue[int] {
+		return stack.NewQueueAdapter[int](2)
+	})
+	require.True(t, q.Empty())
+
+	// full shards are falling back to other shards
+	for i := 0; i < 8; i++ {
+		require.True(t, q.Enqueue(i))
+	}
+	require.True(t, q.Full())
+	require.False(t, q.Enqueue(8))
+	require.Equal(t, 8, q.Size())
+
+	// empty shards are stealing from other shards
+	var res []int
+	for {
+		v, ok := q.Dequeue()
+		if !ok {
+			break
+		}
+		res = append(res, v)
+	}
+	sort.Ints(res)
+	require.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7}, res)
+	require.True(t, q.Empty())
+}
+
+func TestQueue_Concurrent(t *testing.T) {
+	q := NewQueue(0, func() core.Queue[int] {
+		return stack.NewQueueAdapter[int](1024)
+	})
+	writers, n := 8, 1000
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				for !q.Enqueue(w*n + i) {
+					runtime.Gosched()
+				}
+			}
+		}(w)
+	}
+
+	seen := make([]bool, writers*n)
+	var mu sync.Mutex
+	var readers sync.WaitGroup
+	count := 0
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				mu.Lock()
+				done := count == writers*n
+				mu.Unlock()
+				if done {
+					return
+				}
+				v, ok := q.Dequeue()
+				if !ok {
+					continue
+				}
+				mu.Lock()
+				seen[v] = true
+				count++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	readers.Wait()
+	for _, s := range seen {
+		require.True(t, s)
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	require.Equal(t, 4, Capacity(16, 4))
+	require.Equal(t, 5, Capacity(17, 4))
+}
@@ -0,0 +1,415 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/amirylm/go-options"
+	"github.com/amirylm/lockfree/clock"
+	"github.com/amirylm/lockfree/core"
+	"github.com/amirylm/lockfree/idle"
+	"github.com/amirylm/lockfree/internal/shard"
+	"github.com/amirylm/lockfree/stack"
+)
+
+var (
+	// ErrExhausted is returned by non-blocking pools when all resources are in use
+	ErrExhausted = errors.New("pool exhausted")
+	// ErrClosed is returned when getting resources from a closed pool
+	ErrClosed = errors.New("pool is closed")
+)
+
+// Bounded is a pool with an explicit capacity, resources are kept in a lock-free stack (LIFO).
+// Unlike sync.Pool, resources are never dropped and the number of resources is capped.
+type Bounded[T any] struct {
+	generator GeneratorErr[T]
+	available core.Queue[item[T]]
+	// size is the number of resources that were created
+	size     atomic.Int32
+	opts     *Options[T]
+	counters *counters
+
+	// leases holds the creation time of resources that are in use, tracked only when max lifetime is set
+	leases *leases[T]
+	closed atomic.Bool
+	cancel context.CancelFunc
+}
+
+type item[T any] struct {
+	v        T
+	created  time.Time
+	lastUsed time.Time
+}
+
+// NewBounded creates a new bounded pool.
+// A background janitor is started in case idle time or max lifetime are set, see Close.
+// Panics in case max lifetime is set for resources that can't be matched once returned, see WithMaxLifetime.
+func NewBounded[T any](generator Generator[T], opts ...options.Option[Options[T]]) *Bounded[T] {
+	return NewBoundedErr(ignoreErr(generator), opts...)
+}
+
+// NewBoundedErr creates a new bounded pool, where the resource generator might fail.
+// Errors of the generator are returned by Get, see NewBounded.
+func NewBoundedErr[T any](generator GeneratorErr[T], opts ...options.Option[Options[T]]) *Bounded[T] {
+	return newBounded(generator, func(max int) core.Queue[item[T]] {
+		return stack.NewQueueAdapter[item[T]](max)
+	}, opts...)
+}
+
+// NewSharded creates a bounded pool, where the available resources are kept in a stack per processor
+// (or the given number of shards) to reduce contention. Gets are stealing from other shards on a miss.
+func NewSharded[T any](generator Generator[T], shards int, opts ...options.Option[Options[T]]) *Bounded[T] {
+	return NewShardedErr(ignoreErr(generator), shards, opts...)
+}
+
+// NewShardedErr creates a sharded pool, where the resource generator might fail, see NewSharded and NewBoundedErr.
+func NewShardedErr[T any](generator GeneratorErr[T], shards int, opts ...options.Option[Options[T]]) *Bounded[T] {
+	return newBounded(generator, func(max int) core.Queue[item[T]] {
+		// each shard can hold all the resources, as resources are returned to the shard of the current processor
+		return shard.NewQueue(shards, func() core.Queue[item[T]] {
+			return stack.NewQueueAdapter[item[T]](max)
+		})
+	}, opts...)
+}
+
+func newBounded[T any](generator GeneratorErr[T], newAvailable func(max int) core.Queue[item[T]], opts ...options.Option[Options[T]]) *Bounded[T] {
+	o := options.Apply(nil, opts...)
+	if o.max == 0 {
+		o.max = 64
+	}
+	if o.min > o.max {
+		o.min = o.max
+	}
+	if o.clock == nil {
+		o.clock = clock.New()
+	}
+	p := &Bounded[T]{
+		generator: generator,
+		available: newAvailable(o.max),
+		opts:      o,
+		counters:  newCounters(),
+	}
+	if o.maxLifetime > 0 {
+		p.leases = newLeases[T]()
+	}
+	p.fill()
+
+	if o.idleTime > 0 || o.maxLifetime > 0 {
+		interval := o.janitorInterval
+		if interval == 0 {
+			interval = o.idleTime
+			if interval == 0 || (o.maxLifetime > 0 && o.maxLifetime < interval) {
+				interval = o.maxLifetime
+			}
+			interval /= 2
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		p.cancel = cancel
+		go p.janitor(ctx, interval)
+	}
+	return p
+}
+
+// Get returns an available resource, or creates a new one if the pool is not full.
+// Once the pool is exhausted, it waits for a resource to be returned until the context is done,
+// or returns ErrExhausted in case the pool is non-blocking.
+// Returns ErrClosed once the pool is closed, including for callers that are waiting,
+// or the error of the generator in case a new resource couldn't be created.
+func (p *Bounded[T]) Get(ctx context.Context) (T, error) {
+	var wait idle.Strategy
+	var waitStart time.Time
+	idleCount := 0
+	missed := false
+	got := func(it item[T]) (T, error) {
+		t := it.v
+		if p.leases != nil {
+			p.leases.add(t, it.created)
+		}
+		p.opts.get(t)
+		p.counters.gets.Add(1)
+		if missed {
+			p.counters.misses.Add(1)
+		}
+		if wait != nil {
+			p.counters.wait(p.opts.clock.Since(waitStart))
+		} else {
+			p.counters.wait(0)
+		}
+		return t, nil
+	}
+	for {
+		if p.closed.Load() {
+			var t T
+			return t, ErrClosed
+		}
+		if it, ok := p.available.Dequeue(); ok {
+			if p.expired(it) || !p.opts.valid(it.v) {
+				p.evict(it.v)
+				continue
+			}
+			return got(it)
+		}
+		missed = true
+		if size := p.size.Load(); size < int32(p.opts.max) {
+			if p.size.CompareAndSwap(size, size+1) {
+				it, err := p.create()
+				if err != nil {
+					var t T
+					return t, err
+				}
+				return got(it)
+			}
+			continue
+		}
+		var t T
+		if p.opts.nonBlocking {
+			return t, ErrExhausted
+		}
+		if ctx.Err() != nil {
+			return t, ctx.Err()
+		}
+		if wait == nil {
+			wait = idle.Backoff()
+			waitStart = p.opts.clock.Now()
+		}
+		idleCount++
+		wait.Idle(ctx, idleCount)
+	}
+}
+
+// Put returns the resource to the pool, broken resources are discarded.
+func (p *Bounded[T]) Put(t T) {
+	p.counters.puts.Add(1)
+	now := p.opts.clock.Now()
+	it := item[T]{v: t, created: now, lastUsed: now}
+	if p.leases != nil {
+		if created, ok := p.leases.take(t); ok {
+			it.created = created
+		}
+	}
+	if p.closed.Load() {
+		p.evict(t)
+		return
+	}
+	p.opts.put(t)
+	if !p.opts.valid(t) {
+		p.evict(t)
+		return
+	}
+	if !p.available.Enqueue(it) {
+		// should not happen, as the number of resources is capped
+		p.evict(t)
+	}
+}
+
+// release returns the resource to the pool, or discards it in case of an error and discard on error is set
+func (p *Bounded[T]) release(t T, err error) {
+	if err != nil && p.opts.discardOnError {
+		p.Discard(t)
+		return
+	}
+	p.Put(t)
+}
+
+// Discard removes a resource that was taken from the pool, e.g. a broken connection,
+// allowing a new resource to be created instead.
+func (p *Bounded[T]) Discard(t T) {
+	if p.leases != nil {
+		p.leases.take(t)
+	}
+	p.evict(t)
+}
+
+// Size returns the number of resources that were created
+func (p *Bounded[T]) Size() int {
+	return int(p.size.Load())
+}
+
+// Available returns the number of resources that are not in use
+func (p *Bounded[T]) Available() int {
+	return p.available.Size()
+}
+
+// Close stops the janitor and closes the available resources,
+// resources that are in use are closed once they are returned.
+func (p *Bounded[T]) Close() error {
+	if !p.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	if p.cancel != nil {
+		p.cancel()
+	}
+	for {
+		it, ok := p.available.Dequeue()
+		if !ok {
+			return nil
+		}
+		p.evict(it.v)
+	}
+}
+
+// create creates a resource once its size was reserved,
+// the size is given back in case the generator fails or panics.
+func (p *Bounded[T]) create() (it item[T], err error) {
+	created := false
+	defer func() {
+		if !created {
+			p.size.Add(-1)
+		}
+	}()
+	v, err := p.generator()
+	if err != nil {
+		return it, err
+	}
+	created = true
+	p.counters.created.Add(1)
+	now := p.opts.clock.Now()
+	return item[T]{v: v, created: now, lastUsed: now}, nil
+}
+
+// fill creates resources until the pool has the min size, or the generator fails
+func (p *Bounded[T]) fill() {
+	for {
+		size := p.size.Load()
+		if size >= int32(p.opts.min) {
+			return
+		}
+		if p.size.CompareAndSwap(size, size+1) {
+			it, err := p.create()
+			if err != nil {
+				return
+			}
+			p.available.Enqueue(it)
+		}
+	}
+}
+
+func (p *Bounded[T]) expired(it item[T]) bool {
+	return p.opts.maxLifetime > 0 && p.opts.clock.Since(it.created) >= p.opts.maxLifetime
+}
+
+func (p *Bounded[T]) evict(t T) {
+	p.size.Add(-1)
+	p.counters.evicted.Add(1)
+	p.opts.discard(t)
+}
+
+func (p *Bounded[T]) janitor(ctx context.Context, interval time.Duration) {
+	ticker := p.opts.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			p.clean()
+		}
+	}
+}
+
+// clean evicts idle and expired resources, and fills the pool back to its min size.
+func (p *Bounded[T]) clean() {
+	n := p.available.Size()
+	kept := make([]item[T], 0, n)
+	for i := 0; i < n; i++ {
+		it, ok := p.available.Dequeue()
+		if !ok {
+			break
+		}
+		idle := p.opts.idleTime > 0 && p.opts.clock.Since(it.lastUsed) >= p.opts.idleTime
+		if idle || p.expired(it) {
+			p.evict(it.v)
+			continue
+		}
+		kept = append(kept, it)
+	}
+	// pushing back in reverse order, to keep the most recently used resources on top
+	for i := len(kept) - 1; i >= 0; i-- {
+		if !p.available.Enqueue(kept[i]) {
+			p.evict(kept[i].v)
+		}
+	}
+	if !p.closed.Load() {
+		p.fill()
+	}
+}
+
+// BoundedWrapper is a generic wrapper over some function that requires a resource from a bounded pool.
+// An error is returned if a resource couldn't be acquired, see Bounded.Get.
+func BoundedWrapper[T, In, Out any](pool *Bounded[T], fn func(T, In) Out) func(context.Context, In) (Out, error) {
+	return func(ctx context.Context, in In) (Out, error) {
+		t, err := pool.Get(ctx)
+		if err != nil {
+			var out Out
+			return out, err
+		}
+		defer pool.Put(t)
+
+		return fn(t, in), nil
+	}
+}
+
+// BoundedWrapperWithErr is a generic wrapper over some function that requires a resource from a bounded pool,
+// and returns error in addition to some output.
+func BoundedWrapperWithErr[T, In, Out any](pool *Bounded[T], fn func(T, In) (Out, error)) func(context.Context, In) (Out, error) {
+	return func(ctx context.Context, in In) (out Out, err error) {
+		t, err := pool.Get(ctx)
+		if err != nil {
+			return out, err
+		}
+		defer func() {
+			pool.release(t, err)
+		}()
+
+		return fn(t, in)
+	}
+}
+
+// BoundedWrapperErr is a generic wrapper over some function that requires a resource from a bounded pool,
+// the function is expected to return error only.
+func BoundedWrapperErr[T, In any](pool *Bounded[T], fn func(T, In) error) func(context.Context, In) error {
+	return func(ctx context.Context, in In) (err error) {
+		t, err := pool.Get(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			pool.release(t, err)
+		}()
+
+		return fn(t, in)
+	}
+}
+
+// BoundedWrapperCtx is a generic wrapper over some context-aware function that requires a resource from a bounded pool.
+func BoundedWrapperCtx[T, In, Out any](pool *Bounded[T], fn func(context.Context, T, In) (Out, error)) func(context.Context, In) (Out, error) {
+	return func(ctx context.Context, in In) (out Out, err error) {
+		t, err := pool.Get(ctx)
+		if err != nil {
+			return out, err
+		}
+		defer func() {
+			pool.release(t, err)
+		}()
+
+		return fn(ctx, t, in)
+	}
+}
+
+// BoundedWrapperCtxErr is a generic wrapper over some context-aware function that requires a resource from a bounded pool,
+// the function is expected to return error only.
+func BoundedWrapperCtxErr[T, In any](pool *Bounded[T], fn func(context.Context, T, In) error) func(context.Context, In) error {
+	return func(ctx context.Context, in In) (err error) {
+		t, err := pool.Get(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			pool.release(t, err)
+		}()
+
+		return fn(ctx, t, in)
+	}
+}
@@ -0,0 +1,535 @@
+package pool
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/amirylm/lockfree/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBounded(t *testing.T) {
+	var created atomic.Int32
+	gen := func() *int {
+		created.Add(1)
+		return new(int)
+	}
+
+	t.Run("min size", func(t *testing.T) {
+		created.Store(0)
+		p := NewBounded(gen, WithMinSize[*int](2), WithMaxSize[*int](4))
+		require.Equal(t, int32(2), created.Load())
+		require.Equal(t, 2, p.Available())
+	})
+
+	t.Run("non blocking", func(t *testing.T) {
+		p := NewBounded(gen, WithMaxSize[*int](2), WithNonBlocking[*int]())
+		ctx := context.Background()
+		a, err := p.Get(ctx)
+		require.NoError(t, err)
+		b, err := p.Get(ctx)
+		require.NoError(t, err)
+		_, err = p.Get(ctx)
+		require.ErrorIs(t, err, ErrExhausted)
+		require.Equal(t, 2, p.Size())
+
+		p.Put(a)
+		got, err := p.Get(ctx)
+		require.NoError(t, err)
+		require.Same(t, a, got)
+
+		// discarded resources are replaced with new ones
+		p.Discard(b)
+		c, err := p.Get(ctx)
+		require.NoError(t, err)
+		require.NotSame(t, b, c)
+		require.Equal(t, 2, p.Size())
+	})
+
+	t.Run("blocking", func(t *testing.T) {
+		p := NewBounded(gen, WithMaxSize[*int](1))
+		a, err := p.Get(context.Background())
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+		_, err = p.Get(ctx)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		go func() {
+			time.Sleep(time.Millisecond * 5)
+			p.Put(a)
+		}()
+		got, err := p.Get(context.Background())
+		require.NoError(t, err)
+		require.Same(t, a, got)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		created.Store(0)
+		max := 4
+		p := NewBounded(gen, WithMaxSize[*int](max))
+		var inUse, maxInUse atomic.Int32
+		var wg sync.WaitGroup
+		for i := 0; i < 32; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					r, err := p.Get(context.Background())
+					if err != nil {
+						return
+					}
+					n := inUse.Add(1)
+					for {
+						m := maxInUse.Load()
+						if n <= m || maxInUse.CompareAndSwap(m, n) {
+							break
+						}
+					}
+					*r++
+					inUse.Add(-1)
+					p.Put(r)
+				}
+			}()
+		}
+		wg.Wait()
+		require.LessOrEqual(t, maxInUse.Load(), int32(max))
+		require.LessOrEqual(t, created.Load(), int32(max))
+	})
+}
+
+func TestBoundedWrapper(t *testing.T) {
+	p := NewBounded(sha256.New, WithMaxSize[hash.Hash](2))
+	sha256Hash := BoundedWrapper(p, func(h hash.Hash, data []byte) [32]byte {
+		var b [32]byte
+		h.Reset()
+		// #nosec G104
+		_, _ = h.Write(data)
+		h.Sum(b[:0])
+		return b
+	})
+
+	input, expected := bytesInput()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := sha256Hash(context.Background(), input)
+			require.NoError(t, err)
+			require.Equal(t, expected, res)
+		}()
+	}
+	wg.Wait()
+	require.LessOrEqual(t, p.Size(), 2)
+
+	errFailed := errors.New("failed")
+	failing := BoundedWrapperErr(p, func(hash.Hash, []byte) error {
+		return errFailed
+	})
+	require.ErrorIs(t, failing(context.Background(), input), errFailed)
+	require.Equal(t, p.Size(), p.Available())
+}
+
+type resource struct {
+	dirty  bool
+	broken bool
+	closed bool
+}
+
+func TestBounded_Hooks(t *testing.T) {
+	var closed atomic.Int32
+	p := NewBounded(func() *resource {
+		return &resource{}
+	},
+		WithMaxSize[*resource](2),
+		OnGet(func(r *resource) {
+			r.dirty = false
+		}),
+		OnPut(func(r *resource) {
+			require.True(t, r.dirty)
+		}),
+		WithValidate(func(r *resource) bool {
+			return !r.broken
+		}),
+		WithClose(func(r *resource) {
+			r.closed = true
+			closed.Add(1)
+		}),
+	)
+	ctx := context.Background()
+
+	r, err := p.Get(ctx)
+	require.NoError(t, err)
+	r.dirty = true
+	p.Put(r)
+
+	got, err := p.Get(ctx)
+	require.NoError(t, err)
+	require.Same(t, r, got)
+	require.False(t, got.dirty)
+
+	// broken resources are discarded when returned
+	got.dirty = true
+	got.broken = true
+	p.Put(got)
+	require.True(t, got.closed)
+	require.Equal(t, 0, p.Size())
+
+	// and when taken
+	r, err = p.Get(ctx)
+	require.NoError(t, err)
+	r.dirty = true
+	p.Put(r)
+	r.broken = true
+	got, err = p.Get(ctx)
+	require.NoError(t, err)
+	require.NotSame(t, r, got)
+	require.True(t, r.closed)
+	require.Equal(t, int32(2), closed.Load())
+
+	// closing the pool closes available resources, and resources that are returned later
+	got.dirty = true
+	other, err := p.Get(ctx)
+	require.NoError(t, err)
+	p.Put(got)
+	require.NoError(t, p.Close())
+	require.True(t, got.closed)
+	other.dirty = true
+	p.Put(other)
+	require.True(t, other.closed)
+	require.Equal(t, 0, p.Size())
+
+	_, err = p.Get(ctx)
+	require.ErrorIs(t, err, ErrClosed)
+}
+
+func TestBounded_GetClosed(t *testing.T) {
+	p := NewBounded(func() *resource {
+		return &resource{}
+	}, WithMaxSize[*resource](1))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	r, err := p.Get(ctx)
+	require.NoError(t, err)
+	errs := make(chan error, 1)
+	go func() {
+		_, err := p.Get(ctx)
+		errs <- err
+	}()
+	// callers that are waiting for a resource are released once closed
+	require.NoError(t, p.Close())
+	require.ErrorIs(t, <-errs, ErrClosed)
+	p.Put(r)
+	require.Equal(t, 0, p.Size())
+}
+
+func TestBounded_Janitor(t *testing.T) {
+	clk := clock.NewManual(time.Now())
+	p := NewBounded(func() *resource {
+		return &resource{}
+	},
+		WithMinSize[*resource](1),
+		WithMaxSize[*resource](4),
+		WithIdleTime[*resource](time.Minute),
+		WithMaxLifetime[*resource](time.Hour),
+		WithJanitorInterval[*resource](time.Second*10),
+		WithClock[*resource](clk),
+		WithClose(func(r *resource) {
+			r.closed = true
+		}),
+	)
+	defer func() {
+		_ = p.Close()
+	}()
+	clk.BlockUntil(1)
+	ctx := context.Background()
+
+	var rs []*resource
+	for i := 0; i < 3; i++ {
+		r, err := p.Get(ctx)
+		require.NoError(t, err)
+		rs = append(rs, r)
+	}
+	for _, r := range rs {
+		p.Put(r)
+	}
+	require.Equal(t, 3, p.Size())
+
+	// idle resources are evicted, while min size is kept
+	clk.Advance(time.Minute)
+	require.Eventually(t, func() bool {
+		return p.Size() == 1
+	}, time.Second*2, time.Millisecond)
+	for _, r := range rs {
+		require.True(t, r.closed)
+	}
+
+	// resources that reached their max lifetime are evicted once they are taken
+	r, err := p.Get(ctx)
+	require.NoError(t, err)
+	clk.Advance(time.Hour)
+	p.Put(r)
+	got, err := p.Get(ctx)
+	require.NoError(t, err)
+	require.NotSame(t, r, got)
+	require.True(t, r.closed)
+}
+
+func TestBounded_MaxLifetime(t *testing.T) {
+	clk := clock.NewManual(time.Now())
+	var closed [][]byte
+	// slices are not hashable, and are tracked by identity
+	p := NewBounded(func() []byte {
+		return make([]byte, 8)
+	},
+		WithMaxSize[[]byte](2),
+		WithMaxLifetime[[]byte](time.Hour),
+		WithJanitorInterval[[]byte](time.Hour*24),
+		WithClock[[]byte](clk),
+		WithClose(func(b []byte) {
+			closed = append(closed, b)
+		}),
+	)
+	defer func() {
+		_ = p.Close()
+	}()
+	ctx := context.Background()
+
+	a, err := p.Get(ctx)
+	require.NoError(t, err)
+	clk.Advance(time.Minute * 30)
+	b, err := p.Get(ctx)
+	require.NoError(t, err)
+	clk.Advance(time.Minute * 30)
+	// the creation time is kept while resources are in use
+	p.Put(a)
+	p.Put(b)
+
+	got, err := p.Get(ctx)
+	require.NoError(t, err)
+	require.Same(t, &b[0], &got[0])
+	got, err = p.Get(ctx)
+	require.NoError(t, err)
+	require.NotSame(t, &a[0], &got[0])
+	require.Len(t, closed, 1)
+	require.Same(t, &a[0], &closed[0][0])
+
+	// equal values share their creation time
+	vals := NewBounded(func() int {
+		return 1
+	}, WithMaxSize[int](2), WithMaxLifetime[int](time.Hour), WithClock[int](clk))
+	defer func() {
+		_ = vals.Close()
+	}()
+	x, err := vals.Get(ctx)
+	require.NoError(t, err)
+	y, err := vals.Get(ctx)
+	require.NoError(t, err)
+	vals.Put(x)
+	vals.Put(y)
+	require.Equal(t, 2, vals.Available())
+	clk.Advance(time.Hour)
+	_, err = vals.Get(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), vals.Stats().Evicted)
+
+	// leases are released once resources are returned, regardless of the number of gets
+	ptrs := NewBounded(func() *resource {
+		return &resource{}
+	}, WithMaxSize[*resource](1), WithMaxLifetime[*resource](time.Hour), WithClock[*resource](clk), WithClose(func(r *resource) {
+		r.closed = true
+	}))
+	defer func() {
+		_ = ptrs.Close()
+	}()
+	first, err := ptrs.Get(ctx)
+	require.NoError(t, err)
+	ptrs.Put(first)
+	for i := 0; i < 10; i++ {
+		r, err := ptrs.Get(ctx)
+		require.NoError(t, err)
+		require.Same(t, first, r)
+		clk.Advance(time.Minute)
+		ptrs.Put(r)
+	}
+	clk.Advance(time.Hour)
+	r, err := ptrs.Get(ctx)
+	require.NoError(t, err)
+	require.NotSame(t, first, r)
+	require.True(t, first.closed)
+}
+
+func TestBounded_MaxLifetimeNotComparable(t *testing.T) {
+	type conn struct {
+		buf []byte
+	}
+	require.Panics(t, func() {
+		NewBounded(func() conn {
+			return conn{}
+		}, WithMaxLifetime[conn](time.Hour))
+	})
+	// resources of other types are accepted without max lifetime
+	p := NewBounded(func() conn {
+		return conn{}
+	})
+	require.NoError(t, p.Close())
+}
+
+func TestBounded_Stats(t *testing.T) {
+	p := NewBounded(func() *resource {
+		return &resource{}
+	}, WithMinSize[*resource](1), WithMaxSize[*resource](2))
+	ctx := context.Background()
+
+	a, err := p.Get(ctx)
+	require.NoError(t, err)
+	b, err := p.Get(ctx)
+	require.NoError(t, err)
+
+	stats := p.Stats()
+	require.Equal(t, uint64(2), stats.Gets)
+	require.Equal(t, uint64(1), stats.Misses)
+	require.Equal(t, uint64(2), stats.Created)
+	require.Equal(t, 2, stats.InUse)
+	require.Equal(t, 0, stats.Available)
+	require.Equal(t, 0.5, stats.HitRate())
+	require.Equal(t, uint64(2), stats.WaitTime[0])
+
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		p.Put(a)
+	}()
+	got, err := p.Get(ctx)
+	require.NoError(t, err)
+	require.Same(t, a, got)
+	p.Put(got)
+	p.Discard(b)
+
+	stats = p.Stats()
+	require.Equal(t, uint64(3), stats.Gets)
+	require.Equal(t, uint64(2), stats.Puts)
+	require.Equal(t, uint64(2), stats.Misses)
+	require.Equal(t, uint64(1), stats.Evicted)
+	require.Equal(t, 1, stats.Size)
+	require.Equal(t, 1, stats.Available)
+	require.Equal(t, 0, stats.InUse)
+	// the last get waited at least 10ms
+	waited := uint64(0)
+	for i, n := range stats.WaitTime {
+		if i >= 5 {
+			waited += n
+		}
+	}
+	require.Equal(t, uint64(1), waited)
+}
+
+func TestBoundedWrapper_DiscardOnError(t *testing.T) {
+	errBroken := errors.New("broken pipe")
+	p := NewBounded(func() *resource {
+		return &resource{}
+	}, WithMaxSize[*resource](1), WithDiscardOnError[*resource]())
+	exec := BoundedWrapperErr(p, func(r *resource, q string) error {
+		if q == "break" {
+			return errBroken
+		}
+		return nil
+	})
+	require.NoError(t, exec(context.Background(), "a"))
+	require.Equal(t, 1, p.Available())
+	require.ErrorIs(t, exec(context.Background(), "break"), errBroken)
+	require.Equal(t, 0, p.Size())
+	require.Equal(t, uint64(1), p.Stats().Evicted)
+}
+
+func TestBounded_GeneratorErr(t *testing.T) {
+	errDial := errors.New("dial failed")
+	var fail, panics atomic.Bool
+	p := NewBoundedErr(func() (*resource, error) {
+		if panics.Load() {
+			panic("generator panicked")
+		}
+		if fail.Load() {
+			return nil, errDial
+		}
+		return &resource{}, nil
+	}, WithMaxSize[*resource](1))
+	ctx := context.Background()
+
+	// the reserved size is given back once the generator fails or panics
+	fail.Store(true)
+	_, err := p.Get(ctx)
+	require.ErrorIs(t, err, errDial)
+	require.Equal(t, 0, p.Size())
+	panics.Store(true)
+	require.Panics(t, func() {
+		_, _ = p.Get(ctx)
+	})
+	require.Equal(t, 0, p.Size())
+
+	panics.Store(false)
+	fail.Store(false)
+	r, err := p.Get(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	require.Equal(t, 1, p.Size())
+	require.Equal(t, uint64(1), p.Stats().Created)
+}
+
+func TestBoundedWrapperCtx(t *testing.T) {
+	type ctxKey struct{}
+	errBroken := errors.New("broken pipe")
+	p := NewShardedErr(func() (*resource, error) {
+		return &resource{}, nil
+	}, 2, WithMaxSize[*resource](1), WithDiscardOnError[*resource]())
+	query := BoundedWrapperCtx(p, func(ctx context.Context, r *resource, q string) (string, error) {
+		return q + ctx.Value(ctxKey{}).(string), nil
+	})
+	exec := BoundedWrapperCtxErr(p, func(ctx context.Context, r *resource, q string) error {
+		return errBroken
+	})
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "!")
+	res, err := query(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, "a!", res)
+	require.Equal(t, 1, p.Available())
+	require.ErrorIs(t, exec(ctx, "b"), errBroken)
+	require.Equal(t, 0, p.Size())
+}
+
+func TestSharded(t *testing.T) {
+	var created atomic.Int32
+	max := 8
+	p := NewSharded(func() *int {
+		created.Add(1)
+		return new(int)
+	}, 4, WithMinSize[*int](2), WithMaxSize[*int](max))
+	require.Equal(t, 2, p.Available())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				r, err := p.Get(context.Background())
+				if err != nil {
+					return
+				}
+				p.Put(r)
+			}
+		}()
+	}
+	wg.Wait()
+	require.LessOrEqual(t, created.Load(), int32(max))
+	require.Equal(t, p.Size(), p.Available())
+}
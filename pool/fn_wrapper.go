@@ -1,14 +1,21 @@
 package pool
 
 import (
+	"context"
 	"sync"
+
+	"github.com/amirylm/go-options"
 )
 
 // Generator is a constructor function of some resource
 type Generator[T any] func() T
 
+// GeneratorErr is a constructor function of some resource that might fail
+type GeneratorErr[T any] func() (T, error)
+
 // PoolWrapper is a generic wrapper over some function that requires a resource pool.
 // Under the hood, sync.Pool is used to facilitate pooling.
+// Resource hooks can be provided with options, e.g. OnGet to reset resources.
 //
 // Usage example:
 //
@@ -23,13 +30,11 @@ type Generator[T any] func() T
 //			return b
 //		})
 //		h := hashFn([]byte("dummy bytes"))
-func PoolWrapper[T, In, Out any](generator Generator[T], fn func(T, In) Out) func(In) Out {
-	pool := sync.Pool{New: func() interface{} {
-		return generator()
-	}}
+func PoolWrapper[T, In, Out any](generator Generator[T], fn func(T, In) Out, opts ...options.Option[Options[T]]) func(In) Out {
+	pool := newSyncPool(ignoreErr(generator), opts...)
 	return func(in In) Out {
-		t := pool.Get().(T)
-		defer pool.Put(t)
+		t, _ := pool.get()
+		defer pool.put(t)
 
 		return fn(t, in)
 	}
@@ -38,13 +43,13 @@ func PoolWrapper[T, In, Out any](generator Generator[T], fn func(T, In) Out) fun
 // PoolWrapperWithErr is a generic wrapper over some function that requires a resource pool,
 // and returns error in addition to some output.
 // Under the hood, sync.Pool is used to facilitate pooling.
-func PoolWrapperWithErr[T, In, Out any](generator Generator[T], fn func(T, In) (Out, error)) func(In) (Out, error) {
-	pool := sync.Pool{New: func() interface{} {
-		return generator()
-	}}
-	return func(in In) (Out, error) {
-		t := pool.Get().(T)
-		defer pool.Put(t)
+func PoolWrapperWithErr[T, In, Out any](generator Generator[T], fn func(T, In) (Out, error), opts ...options.Option[Options[T]]) func(In) (Out, error) {
+	pool := newSyncPool(ignoreErr(generator), opts...)
+	return func(in In) (out Out, err error) {
+		t, _ := pool.get()
+		defer func() {
+			pool.release(t, err)
+		}()
 
 		return fn(t, in)
 	}
@@ -53,14 +58,105 @@ func PoolWrapperWithErr[T, In, Out any](generator Generator[T], fn func(T, In) (
 // PoolWrapperErr is a generic wrapper over some function that requires a resource pool,
 // the function is expected to return error only.
 // Under the hood, sync.Pool is used to facilitate pooling.
-func PoolWrapperErr[T, In any](generator Generator[T], fn func(T, In) error) func(In) error {
-	pool := sync.Pool{New: func() interface{} {
-		return generator()
-	}}
-	return func(in In) error {
-		t := pool.Get().(T)
-		defer pool.Put(t)
+func PoolWrapperErr[T, In any](generator Generator[T], fn func(T, In) error, opts ...options.Option[Options[T]]) func(In) error {
+	pool := newSyncPool(ignoreErr(generator), opts...)
+	return func(in In) (err error) {
+		t, _ := pool.get()
+		defer func() {
+			pool.release(t, err)
+		}()
 
 		return fn(t, in)
 	}
 }
+
+// PoolWrapperCtx is a generic wrapper over some context-aware function that requires a resource pool,
+// where the resource generator might fail.
+// Under the hood, sync.Pool is used to facilitate pooling.
+func PoolWrapperCtx[T, In, Out any](generator GeneratorErr[T], fn func(context.Context, T, In) (Out, error), opts ...options.Option[Options[T]]) func(context.Context, In) (Out, error) {
+	pool := newSyncPool(generator, opts...)
+	return func(ctx context.Context, in In) (out Out, err error) {
+		if err = ctx.Err(); err != nil {
+			return out, err
+		}
+		t, err := pool.get()
+		if err != nil {
+			return out, err
+		}
+		defer func() {
+			pool.release(t, err)
+		}()
+
+		return fn(ctx, t, in)
+	}
+}
+
+// PoolWrapperCtxErr is a generic wrapper over some context-aware function that requires a resource pool,
+// the function is expected to return error only.
+func PoolWrapperCtxErr[T, In any](generator GeneratorErr[T], fn func(context.Context, T, In) error, opts ...options.Option[Options[T]]) func(context.Context, In) error {
+	wrapped := PoolWrapperCtx(generator, func(ctx context.Context, t T, in In) (struct{}, error) {
+		return struct{}{}, fn(ctx, t, in)
+	}, opts...)
+	return func(ctx context.Context, in In) error {
+		_, err := wrapped(ctx, in)
+		return err
+	}
+}
+
+func ignoreErr[T any](generator Generator[T]) GeneratorErr[T] {
+	return func() (T, error) {
+		return generator(), nil
+	}
+}
+
+// syncPool is a sync.Pool that applies the resource hooks
+type syncPool[T any] struct {
+	pool      sync.Pool
+	generator GeneratorErr[T]
+	opts      *Options[T]
+}
+
+func newSyncPool[T any](generator GeneratorErr[T], opts ...options.Option[Options[T]]) *syncPool[T] {
+	return &syncPool[T]{
+		generator: generator,
+		opts:      options.Apply(nil, opts...),
+	}
+}
+
+func (p *syncPool[T]) get() (T, error) {
+	for {
+		v := p.pool.Get()
+		if v == nil {
+			t, err := p.generator()
+			if err != nil {
+				return t, err
+			}
+			v = t
+		}
+		t := v.(T)
+		if !p.opts.valid(t) {
+			p.opts.discard(t)
+			continue
+		}
+		p.opts.get(t)
+		return t, nil
+	}
+}
+
+func (p *syncPool[T]) put(t T) {
+	p.opts.put(t)
+	if !p.opts.valid(t) {
+		p.opts.discard(t)
+		return
+	}
+	p.pool.Put(t)
+}
+
+// release returns the resource to the pool, or discards it in case of an error and discard on error is set
+func (p *syncPool[T]) release(t T, err error) {
+	if err != nil && p.opts.discardOnError {
+		p.opts.discard(t)
+		return
+	}
+	p.put(t)
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"errors"
 	"hash"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -209,3 +210,80 @@ func bytesInput1024() ([]byte, [32]byte) {
 	}
 	return input, [32]uint8{0x6d, 0x46, 0xcf, 0x6e, 0x4, 0x74, 0x9c, 0xd5, 0x2e, 0x3, 0x68, 0xab, 0x77, 0x14, 0xd, 0xd3, 0x9a, 0xb5, 0x6d, 0x79, 0x3c, 0x0, 0xc3, 0xa5, 0x83, 0xba, 0x92, 0xaf, 0x32, 0x15, 0x63, 0x1f}
 }
+
+func TestPoolWrapper_Hooks(t *testing.T) {
+	var discarded atomic.Int32
+	sha256Hash := PoolWrapper(sha256.New, func(h hash.Hash, data []byte) [32]byte {
+		var b [32]byte
+		// #nosec G104
+		_, _ = h.Write(data)
+		h.Sum(b[:0])
+		return b
+	}, OnPut(func(h hash.Hash) {
+		h.Reset()
+	}), WithValidate(func(h hash.Hash) bool {
+		return h.Size() == sha256.Size
+	}), WithClose(func(hash.Hash) {
+		discarded.Add(1)
+	}))
+
+	input, expected := bytesInput()
+	for i := 0; i < 3; i++ {
+		require.Equal(t, expected, sha256Hash(input))
+	}
+	require.Equal(t, int32(0), discarded.Load())
+}
+
+func TestPoolWrapperCtx(t *testing.T) {
+	errGenerate := errors.New("failed to connect")
+	errBroken := errors.New("broken pipe")
+
+	var created, closed atomic.Int32
+	fail := atomic.Bool{}
+	generator := func() (*resource, error) {
+		if fail.Load() {
+			return nil, errGenerate
+		}
+		created.Add(1)
+		return &resource{}, nil
+	}
+	query := PoolWrapperCtx(generator, func(ctx context.Context, r *resource, q string) (string, error) {
+		if q == "break" {
+			r.broken = true
+			return "", errBroken
+		}
+		return "result:" + q, nil
+	}, WithDiscardOnError[*resource](), WithClose(func(r *resource) {
+		r.closed = true
+		closed.Add(1)
+	}))
+
+	res, err := query(context.Background(), "a")
+	require.NoError(t, err)
+	require.Equal(t, "result:a", res)
+
+	// the resource is discarded rather than returned to the pool
+	_, err = query(context.Background(), "break")
+	require.ErrorIs(t, err, errBroken)
+	require.Equal(t, int32(1), closed.Load())
+
+	// generator errors are returned
+	fail.Store(true)
+	_, err = query(context.Background(), "b")
+	require.ErrorIs(t, err, errGenerate)
+	fail.Store(false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = query(ctx, "c")
+	require.ErrorIs(t, err, context.Canceled)
+
+	exec := PoolWrapperCtxErr(generator, func(ctx context.Context, r *resource, q string) error {
+		if q == "break" {
+			return errBroken
+		}
+		return nil
+	})
+	require.NoError(t, exec(context.Background(), "a"))
+	require.ErrorIs(t, exec(context.Background(), "break"), errBroken)
+}
@@ -0,0 +1,119 @@
+package pool
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// leases holds the creation time of resources that are in use, so it is kept once they are returned.
+// Resources are matched by identity for reference types (pointers, slices, maps and channels), or by value otherwise.
+// Resources with equal keys (e.g. equal values) share the earliest creation time.
+type leases[T any] struct {
+	m   sync.Map
+	key func(T) (any, bool)
+}
+
+type lease struct {
+	created time.Time
+	// n is the number of resources that are in use with the same key
+	n int
+}
+
+// sliceKey is the identity of a slice, as slices are not comparable
+type sliceKey struct {
+	ptr uintptr
+	len int
+}
+
+// mapKey is the identity of a map, as maps are not comparable
+type mapKey uintptr
+
+// newLeases creates leases for the resource type, and panics if resources of that type can't be matched.
+func newLeases[T any]() *leases[T] {
+	typ := reflect.TypeFor[T]()
+	switch typ.Kind() {
+	case reflect.Interface, reflect.Slice, reflect.Map:
+	case reflect.Func:
+		panic(fmt.Sprintf("pool: max lifetime of %v resources can't be tracked, as functions can't be matched", typ))
+	default:
+		if !typ.Comparable() {
+			panic(fmt.Sprintf("pool: max lifetime of %v resources can't be tracked, as they are not comparable", typ))
+		}
+	}
+	return &leases[T]{key: leaseKey[T]}
+}
+
+// leaseKey returns the key of the resource, or false if the resource can't be matched,
+// e.g. interfaces that are holding non-comparable values.
+func leaseKey[T any](t T) (any, bool) {
+	v := reflect.ValueOf(&t).Elem()
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, true
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		return sliceKey{ptr: v.Pointer(), len: v.Len()}, true
+	case reflect.Map:
+		return mapKey(v.Pointer()), true
+	case reflect.Func:
+		return nil, false
+	}
+	if !v.Comparable() {
+		return nil, false
+	}
+	return v.Interface(), true
+}
+
+// add keeps the creation time of a resource that was taken from the pool.
+func (l *leases[T]) add(t T, created time.Time) {
+	key, ok := l.key(t)
+	if !ok {
+		return
+	}
+	for {
+		current, loaded := l.m.LoadOrStore(key, lease{created: created, n: 1})
+		if !loaded {
+			return
+		}
+		cur := current.(lease)
+		if l.m.CompareAndSwap(key, current, lease{created: earliest(cur.created, created), n: cur.n + 1}) {
+			return
+		}
+	}
+}
+
+// take removes the lease of the given resource, and returns its creation time.
+func (l *leases[T]) take(t T) (time.Time, bool) {
+	key, ok := l.key(t)
+	if !ok {
+		return time.Time{}, false
+	}
+	for {
+		current, ok := l.m.Load(key)
+		if !ok {
+			return time.Time{}, false
+		}
+		cur := current.(lease)
+		if cur.n == 1 {
+			if l.m.CompareAndDelete(key, current) {
+				return cur.created, true
+			}
+			continue
+		}
+		if l.m.CompareAndSwap(key, current, lease{created: cur.created, n: cur.n - 1}) {
+			return cur.created, true
+		}
+	}
+}
+
+func earliest(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,136 @@
+package pool
+
+import (
+	"time"
+
+	"github.com/amirylm/go-options"
+	"github.com/amirylm/lockfree/clock"
+)
+
+// Options is the configuration of pools.
+// NOTE: sizes and eviction are applicable only for bounded pools, as sync.Pool can't be inspected.
+type Options[T any] struct {
+	min, max    int
+	nonBlocking bool
+
+	onGet, onPut   func(T)
+	validate       func(T) bool
+	close          func(T)
+	discardOnError bool
+
+	idleTime, maxLifetime time.Duration
+	janitorInterval       time.Duration
+	clock                 clock.Clock
+}
+
+// WithMinSize sets the number of resources that are created upfront, and kept by the janitor.
+func WithMinSize[T any](n int) options.Option[Options[T]] {
+	return func(o *Options[T]) {
+		o.min = n
+	}
+}
+
+// WithMaxSize sets the max number of resources that can exist, defaults to 64.
+func WithMaxSize[T any](n int) options.Option[Options[T]] {
+	return func(o *Options[T]) {
+		o.max = n
+	}
+}
+
+// WithNonBlocking makes Get fail with ErrExhausted instead of waiting for a resource.
+func WithNonBlocking[T any]() options.Option[Options[T]] {
+	return func(o *Options[T]) {
+		o.nonBlocking = true
+	}
+}
+
+// OnGet sets a hook that is called before a resource is handed out, e.g. to reset its state.
+func OnGet[T any](f func(T)) options.Option[Options[T]] {
+	return func(o *Options[T]) {
+		o.onGet = f
+	}
+}
+
+// OnPut sets a hook that is called when a resource is returned to the pool.
+func OnPut[T any](f func(T)) options.Option[Options[T]] {
+	return func(o *Options[T]) {
+		o.onPut = f
+	}
+}
+
+// WithValidate sets a check that is called when resources are taken and returned,
+// broken resources are discarded.
+func WithValidate[T any](f func(T) bool) options.Option[Options[T]] {
+	return func(o *Options[T]) {
+		o.validate = f
+	}
+}
+
+// WithClose sets a destructor that is called for discarded or evicted resources.
+func WithClose[T any](f func(T)) options.Option[Options[T]] {
+	return func(o *Options[T]) {
+		o.close = f
+	}
+}
+
+// WithDiscardOnError makes wrappers discard the resource instead of returning it to the pool,
+// in case the wrapped function returned an error.
+func WithDiscardOnError[T any]() options.Option[Options[T]] {
+	return func(o *Options[T]) {
+		o.discardOnError = true
+	}
+}
+
+// WithIdleTime evicts resources that were not used for the given duration.
+func WithIdleTime[T any](d time.Duration) options.Option[Options[T]] {
+	return func(o *Options[T]) {
+		o.idleTime = d
+	}
+}
+
+// WithMaxLifetime evicts resources that were created before the given duration.
+// Resources that are in use are matched once returned by identity (pointers, slices, maps and channels),
+// or by value for other comparable types. Other types (e.g. functions, or structs with slice fields) are rejected
+// when creating the pool, and should be pooled by pointer instead.
+func WithMaxLifetime[T any](d time.Duration) options.Option[Options[T]] {
+	return func(o *Options[T]) {
+		o.maxLifetime = d
+	}
+}
+
+// WithJanitorInterval sets the interval of the background janitor that evicts resources,
+// defaults to half of the shortest of idle time and max lifetime.
+func WithJanitorInterval[T any](d time.Duration) options.Option[Options[T]] {
+	return func(o *Options[T]) {
+		o.janitorInterval = d
+	}
+}
+
+// WithClock sets the clock that is used for eviction.
+func WithClock[T any](c clock.Clock) options.Option[Options[T]] {
+	return func(o *Options[T]) {
+		o.clock = c
+	}
+}
+
+func (o *Options[T]) valid(t T) bool {
+	return o.validate == nil || o.validate(t)
+}
+
+func (o *Options[T]) discard(t T) {
+	if o.close != nil {
+		o.close(t)
+	}
+}
+
+func (o *Options[T]) get(t T) {
+	if o.onGet != nil {
+		o.onGet(t)
+	}
+}
+
+func (o *Options[T]) put(t T) {
+	if o.onPut != nil {
+		o.onPut(t)
+	}
+}
@@ -0,0 +1,95 @@
+package pool
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// WaitBuckets are the upper bounds of the wait time histogram buckets,
+// waits that are longer than the last bound are counted in an additional bucket.
+var WaitBuckets = []time.Duration{
+	0,
+	time.Microsecond * 10,
+	time.Microsecond * 100,
+	time.Millisecond,
+	time.Millisecond * 10,
+	time.Millisecond * 100,
+	time.Second,
+}
+
+// Stats is a snapshot of the pool counters
+type Stats struct {
+	// Gets is the number of resources that were handed out
+	Gets uint64
+	// Puts is the number of resources that were returned
+	Puts uint64
+	// Misses is the number of gets that didn't find an available resource
+	Misses uint64
+	// Created is the number of resources that were created
+	Created uint64
+	// Evicted is the number of resources that were discarded, evicted or closed
+	Evicted uint64
+	// Size is the current number of resources
+	Size int
+	// Available is the current number of idle resources
+	Available int
+	// InUse is the current number of resources that were handed out and not returned
+	InUse int
+	// WaitTime is the histogram of the time that gets waited for a resource,
+	// where WaitTime[i] is the number of waits up to WaitBuckets[i].
+	WaitTime []uint64
+}
+
+// HitRate returns the ratio of gets that found an available resource
+func (s Stats) HitRate() float64 {
+	if s.Gets == 0 {
+		return 0
+	}
+	return float64(s.Gets-s.Misses) / float64(s.Gets)
+}
+
+type counters struct {
+	gets, puts, misses, created, evicted atomic.Uint64
+	waits                                []atomic.Uint64
+}
+
+func newCounters() *counters {
+	return &counters{
+		waits: make([]atomic.Uint64, len(WaitBuckets)+1),
+	}
+}
+
+func (c *counters) wait(d time.Duration) {
+	i := 0
+	for ; i < len(WaitBuckets); i++ {
+		if d <= WaitBuckets[i] {
+			break
+		}
+	}
+	c.waits[i].Add(1)
+}
+
+// Stats returns a snapshot of the pool counters
+func (p *Bounded[T]) Stats() Stats {
+	waits := make([]uint64, len(p.counters.waits))
+	for i := range waits {
+		waits[i] = p.counters.waits[i].Load()
+	}
+	size, available := p.Size(), p.Available()
+	inUse := size - available
+	if inUse < 0 {
+		// counters are not updated atomically together
+		inUse = 0
+	}
+	return Stats{
+		Gets:      p.counters.gets.Load(),
+		Puts:      p.counters.puts.Load(),
+		Misses:    p.counters.misses.Load(),
+		Created:   p.counters.created.Load(),
+		Evicted:   p.counters.evicted.Load(),
+		Size:      size,
+		Available: available,
+		InUse:     inUse,
+		WaitTime:  waits,
+	}
+}
@@ -5,6 +5,8 @@ import (
 
 	"github.com/amirylm/go-options"
 	"github.com/amirylm/lockfree/core"
+	"github.com/amirylm/lockfree/internal/pad"
+	"github.com/amirylm/lockfree/internal/shard"
 )
 
 type element[Value any] struct {
@@ -14,10 +16,16 @@ type element[Value any] struct {
 
 // Queue is a lock-free queue implemented with linked list,
 // based on atomic compare-and-swap operations.
+// Head, tail and size are kept on separate cache lines, as consumers are updating the head
+// while producers are updating the tail.
 type Queue[Value any] struct {
+	_    pad.CacheLine
 	head atomic.Pointer[element[Value]]
+	_    pad.CacheLine
 	tail atomic.Pointer[element[Value]]
+	_    pad.CacheLine
 	size atomic.Int32
+	_    pad.CacheLine
 
 	capacity int32
 }
@@ -25,7 +33,6 @@ type Queue[Value any] struct {
 func New[Value any](opts ...options.Option[core.Options]) core.Queue[Value] {
 	o := options.Apply(nil, opts...)
 	q := &Queue[Value]{
-		size:     atomic.Int32{},
 		capacity: o.Capacity(),
 	}
 	var e = element[Value]{}
@@ -106,3 +113,15 @@ func (q *Queue[Value]) Range(iterator func(val Value) bool) {
 		current = current.next.Load()
 	}
 }
+
+// NewSharded creates a queue with a shard per processor (or the given number of shards),
+// to reduce contention between goroutines that are running on different cores.
+// The capacity is split between the shards, and full or empty shards are falling back to other shards.
+// NOTE: FIFO order is relaxed, it is kept only between elements of the same shard.
+func NewSharded[Value any](shards int, opts ...options.Option[core.Options]) core.Queue[Value] {
+	o := options.Apply(nil, opts...)
+	capacity := shard.Capacity(int(o.Capacity()), shards)
+	return shard.NewQueue(shards, func() core.Queue[Value] {
+		return New[Value](core.WithCapacity(capacity))
+	})
+}
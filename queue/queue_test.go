@@ -106,3 +106,27 @@ func TestLinkedListQueue_Range(t *testing.T) {
 		})
 	}
 }
+
+func TestLinkedListQueue_Sharded(t *testing.T) {
+	// FIFO order is relaxed, therefore only values are asserted
+	factory := func() core.Queue[int] { return NewSharded[int](4, core.WithCapacity(32)) }
+	utils.SanityTest(t, 32, factory, func(i int) int {
+		return i + 1
+	}, func(i, v int) bool {
+		return v > 0 && v <= 32
+	})
+
+	pctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+	nmsgs := 1024
+	w, r := 5, 5
+	reads, writes := utils.ConcurrencyTest(t, pctx, 128, nmsgs, r, w, func() core.Queue[int] {
+		return NewSharded[int](0, core.WithCapacity(128))
+	}, func(i int) int {
+		return i + 1
+	}, func(i int, v int) bool {
+		return v > 0
+	})
+	require.Equal(t, int64(nmsgs*w), writes, "num of writes is wrong")
+	require.Equal(t, int64(nmsgs*r), reads, "num of reads is wrong")
+}
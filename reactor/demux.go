@@ -2,15 +2,26 @@ package reactor
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"hash/maphash"
 	"io"
 	"runtime"
 	"sync/atomic"
 
 	"github.com/amirylm/go-options"
 	"github.com/amirylm/lockfree/core"
+	"github.com/amirylm/lockfree/idle"
 	"github.com/amirylm/lockfree/queue"
 )
 
+var (
+	// ErrDuplicateService is returned when registering a service ID that is already registered
+	ErrDuplicateService = errors.New("duplicate service")
+	// ErrServiceNotFound is returned when unregistering a service ID that is not registered
+	ErrServiceNotFound = errors.New("service not found")
+)
+
 type Service[T any] interface {
 	Select(T) bool
 	Handle(T)
@@ -20,22 +31,61 @@ type Service[T any] interface {
 // Using lock-free queues for events and control messages, and atomic pointers to manage states and workers.
 // This component can be used instead of go channels in cases of multiple parallel readers
 type Demultiplexer[T any] interface {
+	// Close stops the event loop, without waiting for queued events.
+	// Returns ErrNotStarted if the demultiplexer was never started.
 	io.Closer
-	// Start starts the event loop
+	// Start starts the event loop, and blocks until it stops.
+	// A stopped demultiplexer can be started again, while the registered services and queued events are kept.
+	// Returns ErrAlreadyStarted if the event loop is already running.
 	Start(context.Context) error
-	// Enqueue adds a new event to the event queue
+	// State returns the current lifecycle state
+	State() State
+	// Ready returns a channel that is closed once the event loop is running
+	Ready() <-chan struct{}
+	// Shutdown stops accepting new events, and waits for queued events and in-flight handlers
+	// to complete before stopping the event loop.
+	// In case the context is done before, the event loop is stopped and a *ShutdownError is returned.
+	Shutdown(context.Context) error
+	// Enqueue adds a new event to the event queue, events are dropped once shutdown started
 	Enqueue(T)
 	// Register registers handlers. It accepts the event selector, amount of goroutine workers
 	// that will be used to process events, and the handlers that will be called.
 	Register(id string, s Service[T], workers int)
+	// RegisterTopic registers a service that receives only events of the given topic,
+	// events are routed by a topic index instead of calling Select of every service.
+	// The service Select is still called for events of its topic.
+	// NOTE: requires WithTopic, otherwise the service is treated as a regular service.
+	RegisterTopic(topic, id string, s Service[T], workers int)
 	// Unregister unregisters handlers
 	Unregister(id string)
+	// RegisterSync registers the service and waits until the event loop has applied it.
+	// Returns ErrDuplicateService if a service with the same ID is already registered.
+	RegisterSync(ctx context.Context, id string, s Service[T], workers int) error
+	// UnregisterSync unregisters the service and waits until the event loop has applied it.
+	// Returns ErrServiceNotFound if there is no such service.
+	UnregisterSync(ctx context.Context, id string) error
+	// Services returns the services that are currently registered in the event loop
+	Services() []ServiceInfo
+}
+
+// ServiceInfo describes a registered service
+type ServiceInfo struct {
+	ID      string
+	Topic   string
+	Workers int
 }
 
 type DemuxOptions[T any] struct {
 	eventQ        core.Queue[T]
 	ctrlQCapacity int
 	cloneFn       func(T) T
+	partitionKey  func(T) string
+	partitions    int
+	partitionCap  int
+	panicHandler  func(string, T, any)
+	maxPanics     int
+	idleStrategy  idle.Strategy
+	topicFn       func(T) string
 }
 
 func WithEventQueue[T any](q core.Queue[T]) options.Option[DemuxOptions[T]] {
@@ -56,10 +106,76 @@ func WithCloneFn[T any](f func(T) T) options.Option[DemuxOptions[T]] {
 	}
 }
 
+// WithPartitionKey makes the demultiplexer hash events into partitions by the given key.
+// Events with the same key are handled sequentially (in enqueue order), while events of
+// different partitions are handled in parallel.
+// NOTE: partitioned events are handled on the partition goroutine, service workers are not used.
+func WithPartitionKey[T any](f func(T) string) options.Option[DemuxOptions[T]] {
+	return func(r *DemuxOptions[T]) {
+		r.partitionKey = f
+	}
+}
+
+// WithPartitions sets the number of partitions, applicable only when a partition key is set.
+// Defaults to 32, non-positive values are ignored.
+func WithPartitions[T any](n int) options.Option[DemuxOptions[T]] {
+	return func(r *DemuxOptions[T]) {
+		r.partitions = n
+	}
+}
+
+// WithPartitionCapacity sets the capacity of each partition queue, defaults to 1024.
+// Once a partition is full, the event loop waits for it to drain (backpressure):
+// events of other partitions are not dispatched meanwhile, while control messages are still applied
+// and the wait is aborted once the event loop is stopped.
+func WithPartitionCapacity[T any](n int) options.Option[DemuxOptions[T]] {
+	return func(r *DemuxOptions[T]) {
+		r.partitionCap = n
+	}
+}
+
+// WithPanicHandler sets a hook that is called with the service ID, event and recovered value
+// whenever a service panics in Select or Handle.
+// Panics of the clone, topic and partition key functions are reported with an empty service ID,
+// and the event is dropped.
+func WithPanicHandler[T any](f func(serviceID string, event T, recovered any)) options.Option[DemuxOptions[T]] {
+	return func(r *DemuxOptions[T]) {
+		r.panicHandler = f
+	}
+}
+
+// WithMaxPanics makes the demultiplexer unregister services once they panicked n times.
+func WithMaxPanics[T any](n int) options.Option[DemuxOptions[T]] {
+	return func(r *DemuxOptions[T]) {
+		r.maxPanics = n
+	}
+}
+
+// WithIdleStrategy sets the strategy of the event loop when there are no events or control messages.
+// Defaults to idle.Yield.
+// NOTE: the strategy must not be shared with other demultiplexers.
+func WithIdleStrategy[T any](s idle.Strategy) options.Option[DemuxOptions[T]] {
+	return func(r *DemuxOptions[T]) {
+		r.idleStrategy = s
+	}
+}
+
+// WithTopic sets the topic extractor of events, used to route events to services registered with RegisterTopic.
+func WithTopic[T any](f func(T) string) options.Option[DemuxOptions[T]] {
+	return func(r *DemuxOptions[T]) {
+		r.topicFn = f
+	}
+}
+
 type serviceWrapper[T any] struct {
-	id      string
-	svc     Service[T]
+	id    string
+	topic string
+	svc   Service[T]
+	// maxWorkers is the amount of workers the service was registered with
+	maxWorkers int
+	// workers is the amount of free workers
 	workers *atomic.Int32
+	panics  *atomic.Int32
 }
 
 type control int32
@@ -72,8 +188,11 @@ const (
 type controlEvent[T any] struct {
 	control control
 	id      string
+	topic   string
 	svc     Service[T]
 	workers int32
+	// ack is notified with the result once the event loop applied the control event, optional
+	ack chan error
 }
 
 type demultiplexer[T any] struct {
@@ -82,6 +201,83 @@ type demultiplexer[T any] struct {
 
 	done    atomic.Pointer[context.CancelFunc]
 	cloneFn func(T) T
+
+	partitionKey  func(T) string
+	partitionSeed maphash.Seed
+	partitions    []*partition[T]
+
+	panicHandler func(string, T, any)
+	maxPanics    int32
+
+	idle idle.Strategy
+
+	// services are the registered services, owned by the event loop
+	services []serviceWrapper[T]
+	topicFn  func(T) string
+	// topics indexes the topic services, owned by the event loop
+	topics map[string][]serviceWrapper[T]
+	// predicates are the services without a topic, owned by the event loop
+	predicates []serviceWrapper[T]
+	// servicesInfo is a snapshot of the registered services, updated by the event loop
+	servicesInfo atomic.Pointer[[]ServiceInfo]
+
+	state atomic.Int32
+	// ready is closed once the event loop is running
+	ready atomic.Pointer[chan struct{}]
+	// stopped is closed once the event loop stops
+	stopped atomic.Pointer[chan struct{}]
+	// draining is set once shutdown started
+	draining atomic.Bool
+	// inflight is the number of events that were dispatched but not handled yet
+	inflight atomic.Int64
+}
+
+// ShutdownError is returned when shutdown didn't complete before the context was done.
+type ShutdownError struct {
+	// Err is the context error
+	Err error
+	// Pending is the number of queued events that were not processed
+	Pending int
+	// InFlight is the number of events that were dispatched but not handled
+	InFlight int
+	// Callbacks is the number of handlers that didn't call their callback
+	Callbacks int
+	// Waiters is the number of EnqueueWait callers that didn't get a result
+	Waiters int
+}
+
+func (e *ShutdownError) Error() string {
+	msg := "shutdown incomplete"
+	for _, c := range []struct {
+		n    int
+		what string
+	}{
+		{e.Pending, "pending events"},
+		{e.InFlight, "in-flight events"},
+		{e.Callbacks, "pending callbacks"},
+		{e.Waiters, "waiters"},
+	} {
+		if c.n > 0 {
+			msg += fmt.Sprintf(", %d %s", c.n, c.what)
+		}
+	}
+	return fmt.Sprintf("%s: %v", msg, e.Err)
+}
+
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
+
+// partition holds the pending events of a subset of keys.
+// A single goroutine at a time drains the partition, which preserves the order of events.
+type partition[T any] struct {
+	q       core.Queue[partitionedEvent[T]]
+	running atomic.Bool
+}
+
+type partitionedEvent[T any] struct {
+	event    T
+	services []serviceWrapper[T]
 }
 
 func NewDemux[T any](opts ...options.Option[DemuxOptions[T]]) Demultiplexer[T] {
@@ -93,49 +289,141 @@ func NewDemux[T any](opts ...options.Option[DemuxOptions[T]]) Demultiplexer[T] {
 	if o.ctrlQCapacity == 0 {
 		o.ctrlQCapacity = 32
 	}
+	if o.partitions <= 0 {
+		o.partitions = 32
+	}
+	if o.partitionCap <= 0 {
+		o.partitionCap = 1024
+	}
+	if o.idleStrategy == nil {
+		o.idleStrategy = idle.Yield()
+	}
 
 	el := &demultiplexer[T]{
 		eventQ:   o.eventQ,
 		controlQ: queue.New[controlEvent[T]](core.WithCapacity(o.ctrlQCapacity)),
 		done:     atomic.Pointer[context.CancelFunc]{},
 		cloneFn:  o.cloneFn,
+
+		panicHandler: o.panicHandler,
+		maxPanics:    int32(o.maxPanics),
+
+		idle:    o.idleStrategy,
+		topicFn: o.topicFn,
+	}
+	ready := make(chan struct{})
+	el.ready.Store(&ready)
+
+	if o.partitionKey != nil {
+		el.partitionKey = o.partitionKey
+		el.partitionSeed = maphash.MakeSeed()
+		el.partitions = make([]*partition[T], o.partitions)
+		for i := range el.partitions {
+			el.partitions[i] = &partition[T]{
+				q: queue.New[partitionedEvent[T]](core.WithCapacity(o.partitionCap)),
+			}
+		}
 	}
 
 	return el
 }
 
+func (r *demultiplexer[T]) State() State {
+	return State(r.state.Load())
+}
+
+func (r *demultiplexer[T]) Ready() <-chan struct{} {
+	return *r.ready.Load()
+}
+
 func (r *demultiplexer[T]) Close() error {
-	cancel := r.done.Load()
-	if cancel == nil {
+	switch r.State() {
+	case StateCreated:
+		return ErrNotStarted
+	case StateStopped:
 		return nil
 	}
-	(*cancel)()
-	r.done.Store(nil)
+	r.state.CompareAndSwap(int32(StateRunning), int32(StateStopping))
+	// cancel is stored before the state is set to running
+	if cancel := r.done.Load(); cancel != nil {
+		(*cancel)()
+	}
 	return nil
 }
 
 func (r *demultiplexer[T]) Start(pctx context.Context) error {
 	ctx, cancel := context.WithCancel(pctx)
-	r.done.Store(&cancel)
-	services := make([]serviceWrapper[T], 0)
+	defer cancel()
+	// done is used as a guard against concurrent starts, it is released once the loop fully stopped
+	if !r.done.CompareAndSwap(nil, &cancel) {
+		return ErrAlreadyStarted
+	}
+	stopped := make(chan struct{})
+	r.stopped.Store(&stopped)
+	r.draining.Store(false)
+	r.state.Store(int32(StateRunning))
+	close(*r.ready.Load())
+	defer func() {
+		r.state.Store(int32(StateStopping))
+		ready := make(chan struct{})
+		r.ready.Store(&ready)
+		close(stopped)
+		r.done.Store(nil)
+		// a concurrent restart might have already set the state to running
+		r.state.CompareAndSwap(int32(StateStopping), int32(StateStopped))
+	}()
+
+	if r.services == nil {
+		r.services = make([]serviceWrapper[T], 0)
+		r.servicesUpdated()
+	}
+	idleCount := 0
 	for ctx.Err() == nil {
-		c, ok := r.controlQ.Dequeue()
-		if ok {
-			services = r.handleControl(services, &c)
+		if r.applyControl() {
+			idleCount = 0
 			continue
 		}
 		e, ok := r.eventQ.Dequeue()
 		if ok {
-			eventServices := r.selectServices(e, services...)
-			go r.handleEvent(r.clone(e), eventServices...)
+			idleCount = 0
+			eventServices, ok := r.route(e)
+			if !ok {
+				continue
+			}
+			if r.partitions != nil {
+				r.dispatchPartition(ctx, e, eventServices)
+				continue
+			}
+			cloned, ok := r.clone(e)
+			if !ok {
+				continue
+			}
+			r.inflight.Add(1)
+			go r.handleEvent(cloned, eventServices...)
 			continue
 		}
-		runtime.Gosched()
+		if r.draining.Load() && r.inflight.Load() == 0 {
+			// queue was drained and all handlers completed
+			return nil
+		}
+		idleCount++
+		r.idle.Idle(ctx, idleCount)
 	}
 
 	return ctx.Err()
 }
 
+// applyControl applies the next control message if any, called by the event loop
+func (r *demultiplexer[T]) applyControl() bool {
+	c, ok := r.controlQ.Dequeue()
+	if !ok {
+		return false
+	}
+	r.services = r.handleControl(r.services, &c)
+	r.servicesUpdated()
+	return true
+}
+
 // Register will add the given service (id, selector and handlers).
 // Note that we filter existing IDs, one must use Unregister ID before trying to register.
 func (r *demultiplexer[T]) Register(serviceID string, service Service[T], workers int) {
@@ -145,6 +433,18 @@ func (r *demultiplexer[T]) Register(serviceID string, service Service[T], worker
 		svc:     service,
 		workers: int32(workers),
 	})
+	r.idle.Signal()
+}
+
+func (r *demultiplexer[T]) RegisterTopic(topic, serviceID string, service Service[T], workers int) {
+	r.controlQ.Enqueue(controlEvent[T]{
+		control: registerService,
+		id:      serviceID,
+		topic:   topic,
+		svc:     service,
+		workers: int32(workers),
+	})
+	r.idle.Signal()
 }
 
 func (r *demultiplexer[T]) Unregister(serviceID string) {
@@ -152,16 +452,120 @@ func (r *demultiplexer[T]) Unregister(serviceID string) {
 		control: unregisterService,
 		id:      serviceID,
 	})
+	r.idle.Signal()
+}
+
+func (r *demultiplexer[T]) RegisterSync(ctx context.Context, serviceID string, service Service[T], workers int) error {
+	return r.controlSync(ctx, controlEvent[T]{
+		control: registerService,
+		id:      serviceID,
+		svc:     service,
+		workers: int32(workers),
+	})
+}
+
+func (r *demultiplexer[T]) UnregisterSync(ctx context.Context, serviceID string) error {
+	return r.controlSync(ctx, controlEvent[T]{
+		control: unregisterService,
+		id:      serviceID,
+	})
+}
+
+// controlSync enqueues the control event and waits for the event loop to acknowledge it
+func (r *demultiplexer[T]) controlSync(ctx context.Context, ce controlEvent[T]) error {
+	ce.ack = make(chan error, 1)
+	for !r.controlQ.Enqueue(ce) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		runtime.Gosched()
+	}
+	r.idle.Signal()
+	select {
+	case err := <-ce.ack:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *demultiplexer[T]) Services() []ServiceInfo {
+	infos := r.servicesInfo.Load()
+	if infos == nil {
+		return []ServiceInfo{}
+	}
+	return append([]ServiceInfo{}, *infos...)
+}
+
+// servicesUpdated rebuilds the topic index and the services snapshot, called by the event loop
+func (r *demultiplexer[T]) servicesUpdated() {
+	infos := make([]ServiceInfo, len(r.services))
+	topics := make(map[string][]serviceWrapper[T])
+	var predicates []serviceWrapper[T]
+	for i, s := range r.services {
+		infos[i] = ServiceInfo{ID: s.id, Topic: s.topic, Workers: s.maxWorkers}
+		if r.topicFn != nil && len(s.topic) > 0 {
+			topics[s.topic] = append(topics[s.topic], s)
+			continue
+		}
+		predicates = append(predicates, s)
+	}
+	r.topics = topics
+	r.predicates = predicates
+	r.servicesInfo.Store(&infos)
+}
+
+func (r *demultiplexer[T]) Shutdown(ctx context.Context) error {
+	switch r.State() {
+	case StateCreated:
+		return ErrNotStarted
+	case StateStopped:
+		return nil
+	}
+	stopped := r.stopped.Load()
+	r.draining.Store(true)
+	r.state.CompareAndSwap(int32(StateRunning), int32(StateStopping))
+	r.idle.Signal()
+	select {
+	case <-*stopped:
+		return nil
+	case <-ctx.Done():
+		err := &ShutdownError{
+			Err:      ctx.Err(),
+			Pending:  r.eventQ.Size(),
+			InFlight: int(r.inflight.Load()),
+		}
+		_ = r.Close()
+		return err
+	}
 }
 
 func (r *demultiplexer[T]) Enqueue(t T) {
+	if r.draining.Load() {
+		return
+	}
 	r.eventQ.Enqueue(t)
+	r.idle.Signal()
+}
+
+// route returns the services of the event topic, and the predicate services that selected the event.
+// Returns false in case the topic function panicked.
+func (r *demultiplexer[T]) route(t T) ([]serviceWrapper[T], bool) {
+	if r.topicFn == nil {
+		return r.selectServices(t, r.services...), true
+	}
+	topic, ok := safeCall(r, t, r.topicFn)
+	if !ok {
+		return nil, false
+	}
+	selected := r.selectServices(t, r.topics[topic]...)
+	return append(selected, r.selectServices(t, r.predicates...)...), true
 }
 
 func (r *demultiplexer[T]) selectServices(t T, serviceWrappers ...serviceWrapper[T]) []serviceWrapper[T] {
 	var selected []serviceWrapper[T]
 	for _, s := range serviceWrappers {
-		if s.svc != nil && s.svc.Select(t) {
+		if s.svc != nil && r.safeSelect(s, t) {
 			selected = append(selected, s)
 		}
 	}
@@ -171,34 +575,168 @@ func (r *demultiplexer[T]) selectServices(t T, serviceWrappers ...serviceWrapper
 // handleEvent handles an event by calling the appropriate handlers.
 // Runs in an event thread, and might spawn worker threads.
 func (r *demultiplexer[T]) handleEvent(t T, services ...serviceWrapper[T]) {
+	defer r.handled()
 	for _, s := range services {
+		cloned, ok := r.clone(t)
+		if !ok {
+			continue
+		}
 		if s.workers.Load() <= 0 {
 			// if there are no available workers, run on the event thread
-			s.svc.Handle(r.clone(t))
+			r.safeHandle(s, cloned)
 			continue
 		}
 		s.workers.Add(-1)
-		go func(t T, workers *atomic.Int32, svc Service[T]) {
-			defer workers.Add(1)
-			svc.Handle(r.clone(t))
-		}(r.clone(t), s.workers, s.svc)
+		r.inflight.Add(1)
+		go func(t T, s serviceWrapper[T]) {
+			defer r.handled()
+			defer s.workers.Add(1)
+			r.safeHandle(s, t)
+		}(cloned, s)
+	}
+}
+
+// handled marks an in-flight event as handled, and wakes up the event loop during shutdown.
+func (r *demultiplexer[T]) handled() {
+	if r.inflight.Add(-1) == 0 && r.draining.Load() {
+		r.idle.Signal()
 	}
 }
 
+// safeSelect calls the service selector, a panic is treated as a negative selection.
+func (r *demultiplexer[T]) safeSelect(s serviceWrapper[T], t T) (selected bool) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			selected = false
+			r.onPanic(s, t, rec)
+		}
+	}()
+	return s.svc.Select(t)
+}
+
+// safeHandle calls the service handler, and recovers from panics.
+// Services that reached the max amount of panics are not called anymore.
+func (r *demultiplexer[T]) safeHandle(s serviceWrapper[T], t T) {
+	if r.maxPanics > 0 && s.panics != nil && s.panics.Load() >= r.maxPanics {
+		return
+	}
+	defer func() {
+		if rec := recover(); rec != nil {
+			r.onPanic(s, t, rec)
+		}
+	}()
+	s.svc.Handle(t)
+}
+
+// safeCall calls a user function of the demultiplexer (e.g. clone or topic functions),
+// a panic is reported to the panic handler with an empty service ID, and false is returned.
+func safeCall[T, R any](r *demultiplexer[T], t T, f func(T) R) (res R, ok bool) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			ok = false
+			if r.panicHandler != nil {
+				r.panicHandler("", t, rec)
+			}
+		}
+	}()
+	return f(t), true
+}
+
+func (r *demultiplexer[T]) onPanic(s serviceWrapper[T], t T, rec any) {
+	if r.panicHandler != nil {
+		r.panicHandler(s.id, t, rec)
+	}
+	if r.maxPanics > 0 && s.panics != nil && s.panics.Add(1) == r.maxPanics {
+		r.Unregister(s.id)
+	}
+}
+
+// dispatchPartition pushes the event into its partition, and ensures the partition is being drained.
+// Runs in the event loop, which is the only producer of partitions.
+// In case the partition is full, control messages are applied while waiting, and the event is dropped
+// once the context is done.
+func (r *demultiplexer[T]) dispatchPartition(ctx context.Context, t T, services []serviceWrapper[T]) {
+	if len(services) == 0 {
+		return
+	}
+	key, ok := safeCall(r, t, r.partitionKey)
+	if !ok {
+		return
+	}
+	cloned, ok := r.clone(t)
+	if !ok {
+		return
+	}
+	p := r.partitions[r.partitionOf(key)]
+	pe := partitionedEvent[T]{
+		event:    cloned,
+		services: services,
+	}
+	r.inflight.Add(1)
+	for !p.q.Enqueue(pe) {
+		if ctx.Err() != nil {
+			r.handled()
+			return
+		}
+		// partition is full, wait for the drainer to catch up
+		r.drainPartition(p)
+		if !r.applyControl() {
+			runtime.Gosched()
+		}
+	}
+	r.drainPartition(p)
+}
+
+func (r *demultiplexer[T]) partitionOf(key string) int {
+	return int(maphash.String(r.partitionSeed, key) % uint64(len(r.partitions)))
+}
+
+// drainPartition spawns a goroutine that handles the events of the given partition,
+// in case there is no such running goroutine.
+func (r *demultiplexer[T]) drainPartition(p *partition[T]) {
+	if !p.running.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		for {
+			pe, ok := p.q.Dequeue()
+			for ok {
+				for _, s := range pe.services {
+					if cloned, ok := r.clone(pe.event); ok {
+						r.safeHandle(s, cloned)
+					}
+				}
+				r.handled()
+				pe, ok = p.q.Dequeue()
+			}
+			p.running.Store(false)
+			// re-check to avoid missing events that were added before we released the partition
+			if p.q.Empty() || !p.running.CompareAndSwap(false, true) {
+				return
+			}
+		}
+	}()
+}
+
 func (r *demultiplexer[T]) handleControl(serviceWrappers []serviceWrapper[T], ce *controlEvent[T]) []serviceWrapper[T] {
 	switch ce.control {
 	case registerService:
 		for _, s := range serviceWrappers {
 			if s.id == ce.id {
+				ce.reply(ErrDuplicateService)
 				return serviceWrappers
 			}
 		}
+		defer ce.reply(nil)
 		workers := &atomic.Int32{}
 		workers.Store(ce.workers)
 		return append(serviceWrappers, serviceWrapper[T]{
-			id:      ce.id,
-			svc:     ce.svc,
-			workers: workers,
+			id:         ce.id,
+			topic:      ce.topic,
+			svc:        ce.svc,
+			maxWorkers: int(ce.workers),
+			workers:    workers,
+			panics:     &atomic.Int32{},
 		})
 	case unregisterService:
 		updated := make([]serviceWrapper[T], len(serviceWrappers))
@@ -209,6 +747,11 @@ func (r *demultiplexer[T]) handleControl(serviceWrappers []serviceWrapper[T], ce
 				i++
 			}
 		}
+		if i == len(serviceWrappers) {
+			ce.reply(ErrServiceNotFound)
+		} else {
+			ce.reply(nil)
+		}
 		if i == 0 {
 			return []serviceWrapper[T]{}
 		}
@@ -218,9 +761,16 @@ func (r *demultiplexer[T]) handleControl(serviceWrappers []serviceWrapper[T], ce
 	}
 }
 
-func (r *demultiplexer[T]) clone(t T) T {
+// clone clones the event if a clone function is set, returns false in case it panicked
+func (r *demultiplexer[T]) clone(t T) (T, bool) {
 	if r.cloneFn != nil {
-		return r.cloneFn(t)
+		return safeCall(r, t, r.cloneFn)
+	}
+	return t, true
+}
+
+func (ce *controlEvent[T]) reply(err error) {
+	if ce.ack != nil {
+		ce.ack <- err
 	}
-	return t
 }
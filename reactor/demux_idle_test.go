@@ -0,0 +1,65 @@
+//go:build unix
+
+package reactor
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/amirylm/lockfree/idle"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDemux_IdleCPU(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy idle.Strategy
+	}{
+		{"park", idle.Park()},
+		{"backoff", idle.Backoff()},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			d := NewDemux(WithIdleStrategy[[]byte](tc.strategy))
+			go func() {
+				_ = d.Start(ctx)
+			}()
+			defer func() {
+				_ = d.Close()
+			}()
+
+			cs := NewCountService()
+			d.Register("count", cs, 0)
+			d.Enqueue([]byte("wake up"))
+			for cs.getCount() < 1 && ctx.Err() == nil {
+				time.Sleep(time.Millisecond)
+			}
+			require.Equal(t, int32(1), cs.getCount())
+
+			wall := time.Millisecond * 300
+			before := cpuTime(t)
+			time.Sleep(wall)
+			used := cpuTime(t) - before
+			require.Less(t, used, wall/5, "idle demux used %s of CPU in %s", used, wall)
+
+			// still responsive after idling
+			d.Enqueue([]byte("wake up again"))
+			for cs.getCount() < 2 && ctx.Err() == nil {
+				time.Sleep(time.Millisecond)
+			}
+			require.Equal(t, int32(2), cs.getCount())
+		})
+	}
+}
+
+func cpuTime(t *testing.T) time.Duration {
+	var ru syscall.Rusage
+	require.NoError(t, syscall.Getrusage(syscall.RUSAGE_SELF, &ru))
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/amirylm/go-options"
 	"github.com/amirylm/lockfree/core"
 	"github.com/amirylm/lockfree/queue"
 	"github.com/stretchr/testify/require"
@@ -120,6 +121,7 @@ func TestDemux_handleControl(t *testing.T) {
 				{
 					id:      "test",
 					workers: &atomic_workers,
+					panics:  &atomic.Int32{},
 				},
 			},
 		},
@@ -173,11 +175,14 @@ func TestDemux_handleControl(t *testing.T) {
 					id:      "test2",
 					svc:     test_service,
 					workers: &atomic_workers,
+					panics:  &atomic.Int32{},
 				},
 				{
-					id:      "test3",
-					svc:     test_service,
-					workers: &atomic_workers_2,
+					id:         "test3",
+					svc:        test_service,
+					maxWorkers: 2,
+					workers:    &atomic_workers_2,
+					panics:     &atomic.Int32{},
 				},
 			},
 		},
@@ -239,3 +244,566 @@ func (cs CountService) getCount() int32 {
 }
 func (cs CountService) Handle(b []byte) {
 }
+
+type keyedEvent struct {
+	key string
+	seq int
+}
+
+type orderService struct {
+	last    []atomic.Int64
+	handled atomic.Int64
+	errs    atomic.Int64
+}
+
+func (s *orderService) Select(keyedEvent) bool {
+	return true
+}
+
+func (s *orderService) Handle(e keyedEvent) {
+	var k int
+	_, _ = fmt.Sscanf(e.key, "key-%d", &k)
+	if !s.last[k].CompareAndSwap(int64(e.seq-1), int64(e.seq)) {
+		s.errs.Add(1)
+	}
+	s.handled.Add(1)
+}
+
+func TestDemux_PartitionKey(t *testing.T) {
+	nkeys, nseq := 2000, 10
+	d := NewDemux(
+		WithEventQueue(queue.New[keyedEvent](core.WithCapacity(nkeys*nseq))),
+		WithPartitionKey(func(e keyedEvent) string {
+			return e.key
+		}),
+		WithPartitions[keyedEvent](16),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	go func() {
+		_ = d.Start(ctx)
+	}()
+	defer func() {
+		_ = d.Close()
+	}()
+
+	svc := &orderService{last: make([]atomic.Int64, nkeys)}
+	d.Register("order", svc, 4)
+
+	for seq := 1; seq <= nseq; seq++ {
+		for k := 0; k < nkeys; k++ {
+			d.Enqueue(keyedEvent{key: fmt.Sprintf("key-%d", k), seq: seq})
+		}
+	}
+
+	expected := int64(nkeys * nseq)
+	for svc.handled.Load() < expected && ctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	require.Equal(t, expected, svc.handled.Load())
+	require.Equal(t, int64(0), svc.errs.Load(), "events were handled out of order")
+	for k := range svc.last {
+		require.Equal(t, int64(nseq), svc.last[k].Load())
+	}
+}
+
+type blockingService struct {
+	unblock chan struct{}
+	handled atomic.Int32
+}
+
+func (s *blockingService) Select(keyedEvent) bool {
+	return true
+}
+
+func (s *blockingService) Handle(e keyedEvent) {
+	if e.seq == 0 {
+		<-s.unblock
+	} else {
+		close(s.unblock)
+	}
+	s.handled.Add(1)
+}
+
+func TestDemux_PartitionKey_Parallel(t *testing.T) {
+	d := NewDemux(WithPartitionKey(func(e keyedEvent) string {
+		return e.key
+	})).(*demultiplexer[keyedEvent])
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	go func() {
+		_ = d.Start(ctx)
+	}()
+	defer func() {
+		_ = d.Close()
+	}()
+
+	// find two keys that belong to different partitions
+	keyA, keyB := "key-a", ""
+	for i := 0; keyB == ""; i++ {
+		k := fmt.Sprintf("key-b-%d", i)
+		if d.partitionOf(k) != d.partitionOf(keyA) {
+			keyB = k
+		}
+	}
+
+	svc := &blockingService{unblock: make(chan struct{})}
+	d.Register("blocking", svc, 0)
+
+	// the first event blocks its partition until the second one is handled
+	d.Enqueue(keyedEvent{key: keyA, seq: 0})
+	d.Enqueue(keyedEvent{key: keyB, seq: 1})
+
+	for svc.handled.Load() < 2 && ctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	require.Equal(t, int32(2), svc.handled.Load())
+}
+
+type panicService struct {
+	panicSelect, panicHandle bool
+	handled                  atomic.Int32
+}
+
+func (s *panicService) Select([]byte) bool {
+	if s.panicSelect {
+		panic("select panic")
+	}
+	return true
+}
+
+func (s *panicService) Handle([]byte) {
+	s.handled.Add(1)
+	if s.panicHandle {
+		panic("handle panic")
+	}
+}
+
+func TestDemux_Panics(t *testing.T) {
+	tests := []struct {
+		name      string
+		svc       *panicService
+		workers   int
+		opts      []options.Option[DemuxOptions[[]byte]]
+		maxPanics int
+	}{
+		{
+			name: "select",
+			svc:  &panicService{panicSelect: true},
+		},
+		{
+			name: "handle on event thread",
+			svc:  &panicService{panicHandle: true},
+		},
+		{
+			name:    "handle on worker",
+			svc:     &panicService{panicHandle: true},
+			workers: 2,
+		},
+		{
+			name: "handle on partition",
+			svc:  &panicService{panicHandle: true},
+			opts: []options.Option[DemuxOptions[[]byte]]{
+				WithPartitionKey(func(b []byte) string {
+					return string(b)
+				}),
+			},
+		},
+		{
+			name: "unregister after max panics",
+			svc:  &panicService{panicHandle: true},
+			// using a single partition to handle events sequentially
+			opts: []options.Option[DemuxOptions[[]byte]]{
+				WithPartitionKey(func(b []byte) string {
+					return "single"
+				}),
+			},
+			maxPanics: 3,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			var panics atomic.Int32
+			opts := append(tc.opts, WithPanicHandler(func(id string, e []byte, rec any) {
+				require.Equal(t, "panic", id)
+				require.NotNil(t, rec)
+				panics.Add(1)
+			}), WithMaxPanics[[]byte](tc.maxPanics))
+			d := NewDemux(opts...)
+			go func() {
+				_ = d.Start(ctx)
+			}()
+			defer func() {
+				_ = d.Close()
+			}()
+
+			healthy := NewCountService()
+			d.Register("panic", tc.svc, tc.workers)
+			d.Register("healthy", healthy, tc.workers)
+
+			n := int32(10)
+			for i := int32(0); i < n; i++ {
+				d.Enqueue([]byte(fmt.Sprintf("event-%d", i)))
+			}
+			for (healthy.getCount() < n || panics.Load() < n) && ctx.Err() == nil {
+				if tc.maxPanics > 0 && panics.Load() == int32(tc.maxPanics) && healthy.getCount() == n {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+			require.Equal(t, n, healthy.getCount())
+			if tc.maxPanics > 0 {
+				require.Equal(t, int32(tc.maxPanics), panics.Load())
+				require.Equal(t, int32(tc.maxPanics), tc.svc.handled.Load())
+				return
+			}
+			require.Equal(t, n, panics.Load())
+		})
+	}
+}
+
+type handleService struct {
+	handle func([]byte)
+}
+
+func (s *handleService) Select([]byte) bool {
+	return true
+}
+
+func (s *handleService) Handle(b []byte) {
+	s.handle(b)
+}
+
+func TestDemux_CallbackPanics(t *testing.T) {
+	bad := func(b []byte) {
+		if string(b) == "bad" {
+			panic("bad event")
+		}
+	}
+	tests := []struct {
+		name string
+		opts []options.Option[DemuxOptions[[]byte]]
+	}{
+		{
+			name: "clone",
+			opts: []options.Option[DemuxOptions[[]byte]]{WithCloneFn(func(b []byte) []byte {
+				bad(b)
+				return b
+			})},
+		},
+		{
+			name: "topic",
+			opts: []options.Option[DemuxOptions[[]byte]]{WithTopic(func(b []byte) string {
+				bad(b)
+				return ""
+			})},
+		},
+		{
+			name: "partition key",
+			opts: []options.Option[DemuxOptions[[]byte]]{WithPartitionKey(func(b []byte) string {
+				bad(b)
+				return string(b)
+			})},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			var panics atomic.Int32
+			d := NewDemux(append(tc.opts, WithPanicHandler(func(id string, e []byte, rec any) {
+				require.Empty(t, id)
+				require.Equal(t, "bad", string(e))
+				panics.Add(1)
+			}))...)
+			go func() {
+				_ = d.Start(ctx)
+			}()
+			defer func() {
+				_ = d.Close()
+			}()
+
+			var handled atomic.Int32
+			svc := &handleService{handle: func(b []byte) {
+				require.Equal(t, "good", string(b))
+				handled.Add(1)
+			}}
+			require.NoError(t, d.RegisterSync(ctx, "svc", svc, 1))
+			// the bad event is dropped, while the event loop keeps running
+			d.Enqueue([]byte("bad"))
+			d.Enqueue([]byte("good"))
+			for handled.Load() < 1 && ctx.Err() == nil {
+				time.Sleep(time.Millisecond)
+			}
+			require.Equal(t, int32(1), handled.Load())
+			require.GreaterOrEqual(t, panics.Load(), int32(1))
+		})
+	}
+}
+
+type slowService struct {
+	delay   time.Duration
+	block   chan struct{}
+	handled atomic.Int32
+}
+
+func (s *slowService) Select([]byte) bool {
+	return true
+}
+
+func (s *slowService) Handle([]byte) {
+	if s.block != nil {
+		<-s.block
+	}
+	time.Sleep(s.delay)
+	s.handled.Add(1)
+}
+
+func TestDemux_Shutdown(t *testing.T) {
+	t.Run("drain", func(t *testing.T) {
+		d := NewDemux[[]byte]()
+		started := make(chan error, 1)
+		go func() {
+			started <- d.Start(context.Background())
+		}()
+
+		<-d.Ready()
+
+		svc := &slowService{delay: time.Millisecond}
+		d.Register("slow", svc, 2)
+		n := int32(100)
+		for i := int32(0); i < n; i++ {
+			d.Enqueue([]byte("hello"))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		require.NoError(t, d.Shutdown(ctx))
+		require.NoError(t, <-started)
+		require.Equal(t, n, svc.handled.Load())
+
+		// new events are rejected
+		d.Enqueue([]byte("hello"))
+		require.Equal(t, 0, d.(*demultiplexer[[]byte]).eventQ.Size())
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		d := NewDemux[[]byte]()
+		go func() {
+			_ = d.Start(context.Background())
+		}()
+
+		svc := &slowService{block: make(chan struct{})}
+		defer close(svc.block)
+		d.Register("blocked", svc, 1)
+		d.Enqueue([]byte("hello"))
+		for d.(*demultiplexer[[]byte]).inflight.Load() == 0 {
+			runtime.Gosched()
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+		defer cancel()
+		err := d.Shutdown(ctx)
+		var serr *ShutdownError
+		require.ErrorAs(t, err, &serr)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, 1, serr.InFlight)
+		require.Contains(t, err.Error(), "1 in-flight events")
+	})
+}
+
+func TestDemux_Lifecycle(t *testing.T) {
+	d := NewDemux[[]byte]()
+	require.Equal(t, StateCreated, d.State())
+	require.ErrorIs(t, d.Close(), ErrNotStarted)
+	require.ErrorIs(t, d.Shutdown(context.Background()), ErrNotStarted)
+
+	cs := NewCountService()
+	// registered before start, applied once the loop is running
+	d.Register("count", cs, 0)
+
+	for round := int32(1); round <= 2; round++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		stopped := make(chan error, 1)
+		go func() {
+			stopped <- d.Start(ctx)
+		}()
+		select {
+		case <-d.Ready():
+		case <-ctx.Done():
+			t.Fatal("demux is not ready")
+		}
+		require.Equal(t, StateRunning, d.State())
+		require.ErrorIs(t, d.Start(ctx), ErrAlreadyStarted)
+
+		d.Enqueue([]byte("hello"))
+		for cs.getCount() < round && ctx.Err() == nil {
+			runtime.Gosched()
+		}
+		require.Equal(t, round, cs.getCount())
+
+		require.NoError(t, d.Close())
+		require.ErrorIs(t, <-stopped, context.Canceled)
+		require.Equal(t, StateStopped, d.State())
+		require.NoError(t, d.Close())
+		require.NoError(t, d.Shutdown(ctx))
+		cancel()
+	}
+}
+
+func TestDemux_RegisterSync(t *testing.T) {
+	d := NewDemux[[]byte]()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	go func() {
+		_ = d.Start(ctx)
+	}()
+
+	cs := NewCountService()
+	require.NoError(t, d.RegisterSync(ctx, "count", cs, 2))
+	require.ErrorIs(t, d.RegisterSync(ctx, "count", cs, 2), ErrDuplicateService)
+	require.Equal(t, []ServiceInfo{{ID: "count", Workers: 2}}, d.Services())
+
+	// the service is live once registered
+	d.Enqueue([]byte("hello"))
+	for cs.getCount() < 1 && ctx.Err() == nil {
+		runtime.Gosched()
+	}
+	require.Equal(t, int32(1), cs.getCount())
+
+	require.NoError(t, d.UnregisterSync(ctx, "count"))
+	require.ErrorIs(t, d.UnregisterSync(ctx, "count"), ErrServiceNotFound)
+	require.Empty(t, d.Services())
+
+	t.Run("not running", func(t *testing.T) {
+		d := NewDemux[[]byte]()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+		require.ErrorIs(t, d.RegisterSync(ctx, "count", cs, 1), context.DeadlineExceeded)
+	})
+}
+
+func TestDemux_Topics(t *testing.T) {
+	d := NewDemux(WithTopic(func(e keyedEvent) string {
+		return e.key
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	go func() {
+		_ = d.Start(ctx)
+	}()
+
+	a, b, all := &topicService{}, &topicService{}, &topicService{}
+	d.RegisterTopic("a", "a", a, 0)
+	d.RegisterTopic("b", "b", b, 0)
+	require.NoError(t, d.RegisterSync(ctx, "all", all, 0))
+	require.Equal(t, []ServiceInfo{{ID: "a", Topic: "a"}, {ID: "b", Topic: "b"}, {ID: "all"}}, d.Services())
+
+	d.Enqueue(keyedEvent{key: "a"})
+	d.Enqueue(keyedEvent{key: "a"})
+	d.Enqueue(keyedEvent{key: "b"})
+	d.Enqueue(keyedEvent{key: "c"})
+	for all.handled.Load() < 4 && ctx.Err() == nil {
+		runtime.Gosched()
+	}
+	require.NoError(t, d.Shutdown(ctx))
+	require.Equal(t, int32(2), a.handled.Load())
+	require.Equal(t, int32(1), b.handled.Load())
+	require.Equal(t, int32(4), all.handled.Load())
+}
+
+type topicService struct {
+	handled atomic.Int32
+}
+
+func (s *topicService) Select(keyedEvent) bool {
+	return true
+}
+
+func (s *topicService) Handle(keyedEvent) {
+	s.handled.Add(1)
+}
+
+type gateService struct {
+	gate    chan struct{}
+	handled atomic.Int32
+}
+
+func (s *gateService) Select(keyedEvent) bool {
+	return true
+}
+
+func (s *gateService) Handle(keyedEvent) {
+	<-s.gate
+	s.handled.Add(1)
+}
+
+func TestDemux_ServicesWorkers(t *testing.T) {
+	d := NewDemux[keyedEvent]()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	go func() {
+		_ = d.Start(ctx)
+	}()
+
+	svc := &gateService{gate: make(chan struct{})}
+	defer close(svc.gate)
+	require.NoError(t, d.RegisterSync(ctx, "gate", svc, 2))
+	d.Enqueue(keyedEvent{})
+	for d.(*demultiplexer[keyedEvent]).eventQ.Size() > 0 && ctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+
+	// busy workers are still reported, as the amount of registered workers
+	require.NoError(t, d.RegisterSync(ctx, "other", &gateService{}, 0))
+	require.Equal(t, []ServiceInfo{{ID: "gate", Workers: 2}, {ID: "other"}}, d.Services())
+}
+
+func TestDemux_PartitionBackpressure(t *testing.T) {
+	d := NewDemux(
+		WithPartitionKey(func(e keyedEvent) string {
+			return e.key
+		}),
+		WithPartitions[keyedEvent](-1),
+		WithPartitionCapacity[keyedEvent](1),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	go func() {
+		_ = d.Start(ctx)
+	}()
+	defer func() {
+		_ = d.Close()
+	}()
+	<-d.Ready()
+
+	svc := &gateService{gate: make(chan struct{})}
+	require.NoError(t, d.RegisterSync(ctx, "gate", svc, 0))
+	// the first event blocks the partition, the second fills it and the third blocks the event loop
+	for i := 0; i < 3; i++ {
+		d.Enqueue(keyedEvent{key: "a", seq: i})
+	}
+	for d.(*demultiplexer[keyedEvent]).eventQ.Size() > 0 && ctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+
+	// control messages are applied while waiting for the partition
+	regCtx, regCancel := context.WithTimeout(ctx, time.Second)
+	defer regCancel()
+	require.NoError(t, d.RegisterSync(regCtx, "other", &gateService{}, 0))
+	require.Len(t, d.Services(), 2)
+
+	close(svc.gate)
+	for svc.handled.Load() < 3 && ctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	require.Equal(t, int32(3), svc.handled.Load())
+}
@@ -0,0 +1,37 @@
+package reactor
+
+import "errors"
+
+var (
+	// ErrAlreadyStarted is returned when starting a running demultiplexer
+	ErrAlreadyStarted = errors.New("already started")
+	// ErrNotStarted is returned when stopping a demultiplexer that was never started
+	ErrNotStarted = errors.New("not started")
+)
+
+// State is the lifecycle state of a demultiplexer:
+//
+//	created -> running -> stopping -> stopped -> running ...
+type State int32
+
+const (
+	StateCreated State = iota
+	StateRunning
+	StateStopping
+	StateStopped
+)
+
+func (s State) String() string {
+	switch s {
+	case StateCreated:
+		return "created"
+	case StateRunning:
+		return "running"
+	case StateStopping:
+		return "stopping"
+	case StateStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,155 @@
+package reactor
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/amirylm/go-options"
+	"github.com/amirylm/lockfree/clock"
+)
+
+var (
+	// ErrPanic is returned to callbacks of handlers that panicked, see Recoverer.
+	ErrPanic = errors.New("handler panicked")
+	// ErrHandlerTimeout is returned to callbacks of handlers that didn't complete in time, see Timeout.
+	ErrHandlerTimeout = errors.New("handler timeout")
+)
+
+// Middleware wraps a service with some additional behavior.
+type Middleware[T any] func(Service[T]) Service[T]
+
+// ReactiveMiddleware wraps a reactive service with some additional behavior.
+type ReactiveMiddleware[T, C any] func(ReactiveService[T, C]) ReactiveService[T, C]
+
+// Chain wraps the given service with middlewares, the first middleware is the outermost one.
+func Chain[T any](svc Service[T], mws ...Middleware[T]) Service[T] {
+	for i := len(mws) - 1; i >= 0; i-- {
+		svc = mws[i](svc)
+	}
+	return svc
+}
+
+// ChainReactive wraps the given reactive service with middlewares, the first middleware is the outermost one.
+func ChainReactive[T, C any](svc ReactiveService[T, C], mws ...ReactiveMiddleware[T, C]) ReactiveService[T, C] {
+	for i := len(mws) - 1; i >= 0; i-- {
+		svc = mws[i](svc)
+	}
+	return svc
+}
+
+// HandlerOptions is the configuration of a single handler, see Reactor.AddHandler.
+type HandlerOptions[T, C any] struct {
+	middlewares []ReactiveMiddleware[T, C]
+	retry       *RetryPolicy
+}
+
+// WithMiddlewares adds middlewares to a specific handler.
+// Handler middlewares are wrapped by the global middlewares of the reactor.
+func WithMiddlewares[T, C any](mws ...ReactiveMiddleware[T, C]) options.Option[HandlerOptions[T, C]] {
+	return func(o *HandlerOptions[T, C]) {
+		o.middlewares = append(o.middlewares, mws...)
+	}
+}
+
+// Recoverer recovers from panics in Handle, and passes ErrPanic to the callback
+// in case it was not called yet.
+func Recoverer[T, C any]() ReactiveMiddleware[T, C] {
+	return func(next ReactiveService[T, C]) ReactiveService[T, C] {
+		return &reactiveMiddlewareService[T, C]{
+			next: next,
+			handle: func(e Event[T], callback func(C, error)) {
+				callback = callOnce(callback)
+				defer func() {
+					if r := recover(); r != nil {
+						var c C
+						callback(c, fmt.Errorf("%w: %v", ErrPanic, r))
+					}
+				}()
+				next.Handle(e, callback)
+			},
+		}
+	}
+}
+
+// Timeout passes ErrHandlerTimeout to the callback in case the handler didn't call it within the given duration.
+// Late callbacks are ignored.
+func Timeout[T, C any](timeout time.Duration) ReactiveMiddleware[T, C] {
+	return TimeoutWithClock[T, C](clock.New(), timeout)
+}
+
+// TimeoutWithClock is the same as Timeout, using the given clock.
+func TimeoutWithClock[T, C any](c clock.Clock, timeout time.Duration) ReactiveMiddleware[T, C] {
+	return func(next ReactiveService[T, C]) ReactiveService[T, C] {
+		return &reactiveMiddlewareService[T, C]{
+			next: next,
+			handle: func(e Event[T], callback func(C, error)) {
+				callback = callOnce(callback)
+				timer := c.AfterFunc(timeout, func() {
+					var c C
+					callback(c, ErrHandlerTimeout)
+				})
+				next.Handle(e, func(c C, err error) {
+					timer.Stop()
+					callback(c, err)
+				})
+			},
+		}
+	}
+}
+
+// Logger logs the result of each handled event, including the time it took to complete.
+func Logger[T, C any](logger *slog.Logger) ReactiveMiddleware[T, C] {
+	return LoggerWithClock[T, C](clock.New(), logger)
+}
+
+// LoggerWithClock is the same as Logger, using the given clock to measure durations.
+func LoggerWithClock[T, C any](clk clock.Clock, logger *slog.Logger) ReactiveMiddleware[T, C] {
+	return func(next ReactiveService[T, C]) ReactiveService[T, C] {
+		return &reactiveMiddlewareService[T, C]{
+			next: next,
+			handle: func(e Event[T], callback func(C, error)) {
+				start := clk.Now()
+				next.Handle(e, func(c C, err error) {
+					attrs := []any{
+						slog.String("id", e.ID.String()),
+						slog.Int64("nonce", e.nonce),
+						slog.Duration("duration", clk.Since(start)),
+					}
+					if err != nil {
+						logger.Error("failed to handle event", append(attrs, slog.Any("err", err))...)
+					} else {
+						logger.Debug("handled event", attrs...)
+					}
+					callback(c, err)
+				})
+			},
+		}
+	}
+}
+
+// reactiveMiddlewareService is a reactive service that overrides the Handle function of the wrapped service.
+type reactiveMiddlewareService[T, C any] struct {
+	next   ReactiveService[T, C]
+	handle func(Event[T], func(C, error))
+}
+
+func (s *reactiveMiddlewareService[T, C]) Select(e Event[T]) bool {
+	return s.next.Select(e)
+}
+
+func (s *reactiveMiddlewareService[T, C]) Handle(e Event[T], callback func(C, error)) {
+	s.handle(e, callback)
+}
+
+// callOnce ensures the given callback is called at most once.
+func callOnce[C any](callback func(C, error)) func(C, error) {
+	called := atomic.Bool{}
+	return func(c C, err error) {
+		if called.CompareAndSwap(false, true) {
+			callback(c, err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package reactor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/amirylm/lockfree/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChainReactive(t *testing.T) {
+	var order []string
+	mw := func(name string) ReactiveMiddleware[mockEventData, mockEventData] {
+		return func(next ReactiveService[mockEventData, mockEventData]) ReactiveService[mockEventData, mockEventData] {
+			return &reactiveMiddlewareService[mockEventData, mockEventData]{
+				next: next,
+				handle: func(e Event[mockEventData], callback func(mockEventData, error)) {
+					order = append(order, name)
+					next.Handle(e, callback)
+				},
+			}
+		}
+	}
+	svc := ChainReactive[mockEventData, mockEventData](&ReactiveServiceImpl{
+		SelectLogic: func(e Event[mockEventData]) bool {
+			return e.Data.name == "selected"
+		},
+		HandleLogic: func(e Event[mockEventData], callback func(mockEventData, error)) {
+			order = append(order, "svc")
+			callback(e.Data, nil)
+		},
+	}, mw("first"), mw("second"))
+
+	require.True(t, svc.Select(Event[mockEventData]{Data: mockEventData{name: "selected"}}))
+	require.False(t, svc.Select(Event[mockEventData]{}))
+	svc.Handle(Event[mockEventData]{}, func(mockEventData, error) {})
+	require.Equal(t, []string{"first", "second", "svc"}, order)
+}
+
+func TestRecoverer(t *testing.T) {
+	tests := []struct {
+		name   string
+		handle func(e Event[mockEventData], callback func(mockEventData, error))
+		err    error
+		calls  int32
+	}{
+		{
+			name: "panic",
+			handle: func(e Event[mockEventData], callback func(mockEventData, error)) {
+				panic("test-panic")
+			},
+			err:   ErrPanic,
+			calls: 1,
+		},
+		{
+			name: "panic after callback",
+			handle: func(e Event[mockEventData], callback func(mockEventData, error)) {
+				callback(e.Data, nil)
+				panic("test-panic")
+			},
+			calls: 1,
+		},
+		{
+			name: "no panic",
+			handle: func(e Event[mockEventData], callback func(mockEventData, error)) {
+				callback(e.Data, nil)
+			},
+			calls: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := Recoverer[mockEventData, mockEventData]()(&ReactiveServiceImpl{
+				HandleLogic: tc.handle,
+			})
+			var calls atomic.Int32
+			var gotErr error
+			svc.Handle(Event[mockEventData]{}, func(_ mockEventData, err error) {
+				calls.Add(1)
+				gotErr = err
+			})
+			require.Equal(t, tc.calls, calls.Load())
+			if tc.err != nil {
+				require.ErrorIs(t, gotErr, tc.err)
+			} else {
+				require.NoError(t, gotErr)
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	var late func(mockEventData, error)
+	clk := clock.NewManual(time.Now())
+	svc := TimeoutWithClock[mockEventData, mockEventData](clk, time.Millisecond*10)(&ReactiveServiceImpl{
+		HandleLogic: func(e Event[mockEventData], callback func(mockEventData, error)) {
+			late = callback
+		},
+	})
+	errs := make(chan error, 2)
+	svc.Handle(Event[mockEventData]{}, func(_ mockEventData, err error) {
+		errs <- err
+	})
+	clk.Advance(time.Millisecond * 9)
+	require.Len(t, errs, 0, "timeout triggered too early")
+	clk.Advance(time.Millisecond)
+	require.ErrorIs(t, <-errs, ErrHandlerTimeout)
+	late(mockEventData{}, nil)
+	require.Len(t, errs, 0, "late callback should be ignored")
+}
+
+func TestLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	svc := Logger[mockEventData, mockEventData](logger)(&ReactiveServiceImpl{
+		HandleLogic: func(e Event[mockEventData], callback func(mockEventData, error)) {
+			if e.Data.name == "errored" {
+				callback(e.Data, errors.New("test-error"))
+				return
+			}
+			callback(e.Data, nil)
+		},
+	})
+	svc.Handle(Event[mockEventData]{ID: ID("ok")}, func(mockEventData, error) {})
+	svc.Handle(Event[mockEventData]{ID: ID("err"), Data: mockEventData{name: "errored"}}, func(mockEventData, error) {})
+
+	out := buf.String()
+	require.Contains(t, out, `"msg":"handled event"`)
+	require.Contains(t, out, `"msg":"failed to handle event"`)
+	require.Contains(t, out, `"err":"test-error"`)
+	require.Contains(t, out, `"id":"`+ID("err").String()+`"`)
+}
+
+func TestLoggerWithClock(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	clk := clock.NewManual(time.Now())
+	svc := LoggerWithClock[mockEventData, mockEventData](clk, logger)(&ReactiveServiceImpl{
+		HandleLogic: func(e Event[mockEventData], callback func(mockEventData, error)) {
+			clk.Advance(time.Second * 3)
+			callback(e.Data, nil)
+		},
+	})
+	svc.Handle(Event[mockEventData]{ID: ID("ok")}, func(mockEventData, error) {})
+
+	require.Contains(t, buf.String(), `"duration":3000000000`)
+}
+
+func TestReactor_Middlewares(t *testing.T) {
+	pctx, pcancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer pcancel()
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, name)
+	}
+	handlerMw := func(name string) ReactiveMiddleware[mockEventData, mockEventData] {
+		return func(next ReactiveService[mockEventData, mockEventData]) ReactiveService[mockEventData, mockEventData] {
+			return &reactiveMiddlewareService[mockEventData, mockEventData]{
+				next: next,
+				handle: func(e Event[mockEventData], callback func(mockEventData, error)) {
+					record(name)
+					next.Handle(e, callback)
+				},
+			}
+		}
+	}
+	var callbacks atomic.Int32
+	r := New(
+		WithTimeout[mockEventData, mockEventData](time.Second*2),
+		WithHandlerMiddlewares(handlerMw("global"), Recoverer[mockEventData, mockEventData]()),
+		WithCallbackMiddlewares[mockEventData](func(next Service[Event[mockEventData]]) Service[Event[mockEventData]] {
+			callbacks.Add(1)
+			return next
+		}),
+	)
+	go func() {
+		_ = r.Start(pctx)
+	}()
+	defer func() {
+		_ = r.Close()
+	}()
+
+	r.AddHandler("panic", &ReactiveServiceImpl{
+		SelectLogic: func(e Event[mockEventData]) bool {
+			return true
+		},
+		HandleLogic: func(e Event[mockEventData], callback func(mockEventData, error)) {
+			panic("test-panic")
+		},
+	}, 1, WithMiddlewares(handlerMw("local")))
+	r.AddCallback("noop", &CallbackServiceImpl{
+		SelectLogic: func(e Event[mockEventData]) bool {
+			return false
+		},
+	}, 1)
+
+	_, err := r.EnqueueWait(pctx, mockEventData{name: "test"})
+	require.ErrorIs(t, err, ErrPanic)
+	mu.Lock()
+	require.Equal(t, []string{"global", "local"}, calls)
+	mu.Unlock()
+	require.Equal(t, int32(1), callbacks.Load())
+}
@@ -0,0 +1,247 @@
+package reactor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/amirylm/go-options"
+)
+
+// stage is the lifecycle of a reactor within a pipeline, regardless of its types
+type stage interface {
+	io.Closer
+	Start(context.Context) error
+	Shutdown(context.Context) error
+}
+
+// Pipeline connects reactors into a multi-stage flow, where the callback results of each stage
+// are the events of the next stage.
+// Events keep their ID across stages, while the nonce is incremented on each hop.
+// Errors short-circuit the remaining stages, and are delivered to the tail callbacks.
+// Stages can also run within one reactor, see NewStages.
+//
+// NOTE: each reactor can appear once in a pipeline, as the results of a stage are forwarded to the next one.
+type Pipeline[I, O any] struct {
+	head interface {
+		Enqueue(...I)
+		EnqueueEvent(Event[I])
+	}
+	tail interface {
+		AddCallback(string, Service[Event[O]], int, ...Middleware[Event[O]])
+		AddCallbackSync(context.Context, string, Service[Event[O]], int, ...Middleware[Event[O]]) error
+		RemoveCallback(string)
+	}
+	stages []stage
+	// waitContext limits EnqueueWait, using the timeout and clock of the first reactor
+	waitContext func(context.Context) (context.Context, context.CancelFunc)
+	// hops is the nonce of the results of the last stage
+	hops int64
+	// final is set once the last reactor has multiple stages, so only the results of its last stage are selected
+	final int64
+}
+
+// NewPipeline creates a single stage pipeline.
+func NewPipeline[I, O any](r Reactor[I, O]) *Pipeline[I, O] {
+	return &Pipeline[I, O]{
+		head:        r,
+		tail:        r,
+		stages:      []stage{r},
+		waitContext: waitContext(r),
+		hops:        1,
+	}
+}
+
+// StageHandler is the handler of a pipeline stage within a reactor.
+type StageHandler[T any] struct {
+	ID      string
+	Service ReactiveService[T, T]
+	Workers int
+	Opts    []options.Option[HandlerOptions[T, T]]
+}
+
+// NewStages creates a pipeline of stages within one reactor, where the results of each stage are
+// the events of the next one. Stages are added as handlers that select the events of their hop by nonce.
+//
+// NOTE: the reactor should not have other handlers, as they would get the events of all stages.
+// Results that are in-flight between stages are dropped once the reactor is shutting down,
+// and dead events of stages can't be redriven as their nonce is reset.
+func NewStages[T any](r Reactor[T, T], stages ...StageHandler[T]) *Pipeline[T, T] {
+	hops := int64(len(stages))
+	for i, s := range stages {
+		r.AddHandler(s.ID, &stageService[T]{ReactiveService: s.Service, hop: int64(i)}, s.Workers, s.Opts...)
+		if i > 0 {
+			// forwarding the results of the previous stage back into the reactor
+			r.AddCallback(fmt.Sprintf("pipeline-%s", newID()), &forwardService[T]{
+				next:  r,
+				nonce: int64(i),
+			}, 0)
+		}
+	}
+	return &Pipeline[T, T]{
+		head:        r,
+		tail:        r,
+		stages:      []stage{r},
+		waitContext: waitContext(r),
+		hops:        hops,
+		final:       hops,
+	}
+}
+
+// Then returns a pipeline that forwards the results of the given pipeline to the reactor,
+// using the given amount of workers.
+func Then[I, M, O any](p *Pipeline[I, M], r Reactor[M, O], workers int) *Pipeline[I, O] {
+	p.tail.AddCallback(fmt.Sprintf("pipeline-%s", newID()), &forwardService[M]{
+		next:  r,
+		nonce: p.final,
+	}, workers)
+	stages := make([]stage, len(p.stages), len(p.stages)+1)
+	copy(stages, p.stages)
+	return &Pipeline[I, O]{
+		head:        p.head,
+		tail:        r,
+		stages:      append(stages, r),
+		waitContext: p.waitContext,
+		hops:        p.hops + 1,
+	}
+}
+
+// Start starts all the stages, and blocks until they stop.
+func (p *Pipeline[I, O]) Start(ctx context.Context) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(p.stages))
+	for i, s := range p.stages {
+		wg.Add(1)
+		go func(i int, s stage) {
+			defer wg.Done()
+			errs[i] = s.Start(ctx)
+		}(i, s)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Shutdown shuts down the stages in order, so the results of each stage are drained into the next one.
+func (p *Pipeline[I, O]) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, s := range p.stages {
+		errs = append(errs, s.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Pipeline[I, O]) Close() error {
+	var errs []error
+	for _, s := range p.stages {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Enqueue adds events to the first stage
+func (p *Pipeline[I, O]) Enqueue(events ...I) {
+	p.head.Enqueue(events...)
+}
+
+// EnqueueWait adds an event to the first stage, and waits for the result of the last stage.
+// As with Reactor.EnqueueWait, it waits up to the timeout of the first reactor (10 seconds by default),
+// on the clock of that reactor, unless the context is done before.
+func (p *Pipeline[I, O]) EnqueueWait(pctx context.Context, data I) (O, error) {
+	ctx, cancel := p.waitContext(pctx)
+	defer cancel()
+
+	id := newID()
+	resultp := &atomic.Pointer[Event[O]]{}
+	svc := &waitCallbackService[O]{
+		id:     id,
+		nonce:  p.hops,
+		result: resultp,
+		done:   make(chan struct{}),
+	}
+	cid := fmt.Sprintf("%x:%d", id, svc.nonce)
+	if err := p.tail.AddCallbackSync(ctx, cid, svc, 1); err != nil {
+		var res O
+		return res, err
+	}
+	defer p.tail.RemoveCallback(cid)
+
+	p.head.EnqueueEvent(Event[I]{
+		ID:   id,
+		Data: data,
+	})
+
+	select {
+	case <-svc.done:
+	case <-ctx.Done():
+	}
+	if result := resultp.Load(); result != nil {
+		return result.Data, result.Err
+	}
+	var res O
+	return res, ctx.Err()
+}
+
+// AddCallback adds a callback service to the last stage
+func (p *Pipeline[I, O]) AddCallback(id string, svc Service[Event[O]], workers int, mws ...Middleware[Event[O]]) {
+	if p.final > 0 {
+		svc = &hopService[O]{Service: svc, nonce: p.final}
+	}
+	p.tail.AddCallback(id, svc, workers, mws...)
+}
+
+func (p *Pipeline[I, O]) RemoveCallback(id string) {
+	p.tail.RemoveCallback(id)
+}
+
+// waitContext returns the EnqueueWait context of the given reactor,
+// or a context with the default timeout for other implementations of Reactor.
+func waitContext(r any) func(context.Context) (context.Context, context.CancelFunc) {
+	if w, ok := r.(interface {
+		waitContext(context.Context) (context.Context, context.CancelFunc)
+	}); ok {
+		return w.waitContext
+	}
+	return func(ctx context.Context) (context.Context, context.CancelFunc) {
+		return context.WithTimeout(ctx, defaultTimeout)
+	}
+}
+
+// forwardService forwards the results of a stage as events of the next stage
+type forwardService[T any] struct {
+	next interface {
+		EnqueueEvent(Event[T])
+	}
+	// nonce selects the results of a specific hop, all results are forwarded if not set
+	nonce int64
+}
+
+func (f *forwardService[T]) Select(e Event[T]) bool {
+	return f.nonce == 0 || e.nonce == f.nonce
+}
+
+func (f *forwardService[T]) Handle(e Event[T]) {
+	f.next.EnqueueEvent(e)
+}
+
+// stageService selects the events of a stage within a reactor
+type stageService[T any] struct {
+	ReactiveService[T, T]
+	hop int64
+}
+
+func (s *stageService[T]) Select(e Event[T]) bool {
+	return e.nonce == s.hop && s.ReactiveService.Select(e)
+}
+
+// hopService selects the results of the last stage within a reactor
+type hopService[T any] struct {
+	Service[Event[T]]
+	nonce int64
+}
+
+func (s *hopService[T]) Select(e Event[T]) bool {
+	return e.nonce == s.nonce && s.Service.Select(e)
+}
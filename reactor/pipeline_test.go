@@ -0,0 +1,180 @@
+package reactor
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/amirylm/lockfree/clock"
+	"github.com/stretchr/testify/require"
+)
+
+type funcService[T, C any] struct {
+	handle func(Event[T]) (C, error)
+	calls  atomic.Int32
+}
+
+func (s *funcService[T, C]) Select(Event[T]) bool {
+	return true
+}
+
+func (s *funcService[T, C]) Handle(e Event[T], callback func(C, error)) {
+	s.calls.Add(1)
+	callback(s.handle(e))
+}
+
+type funcCallback[T any] struct {
+	handle func(Event[T])
+}
+
+func (s *funcCallback[T]) Select(Event[T]) bool {
+	return true
+}
+
+func (s *funcCallback[T]) Handle(e Event[T]) {
+	s.handle(e)
+}
+
+func TestPipeline(t *testing.T) {
+	errOdd := errors.New("odd")
+
+	parse := &funcService[string, int]{handle: func(e Event[string]) (int, error) {
+		return strconv.Atoi(e.Data)
+	}}
+	even := &funcService[int, int]{handle: func(e Event[int]) (int, error) {
+		if e.Data%2 != 0 {
+			return 0, errOdd
+		}
+		return e.Data * 2, nil
+	}}
+	var lastNonce atomic.Int64
+	format := &funcService[int, string]{handle: func(e Event[int]) (string, error) {
+		lastNonce.Store(e.Nonce())
+		return strconv.Itoa(e.Data), nil
+	}}
+
+	r1 := New[string, int]()
+	r1.AddHandler("parse", parse, 2)
+	r2 := New[int, int]()
+	r2.AddHandler("even", even, 2)
+	r3 := New[int, string]()
+	r3.AddHandler("format", format, 2)
+
+	p := Then(Then(NewPipeline(r1), r2, 1), r3, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	go func() {
+		_ = p.Start(ctx)
+	}()
+
+	res, err := p.EnqueueWait(ctx, "21")
+	require.ErrorIs(t, err, errOdd)
+	require.Empty(t, res)
+	// the last stage was skipped
+	require.Equal(t, int32(0), format.calls.Load())
+
+	res, err = p.EnqueueWait(ctx, "nan")
+	require.Error(t, err)
+	require.Empty(t, res)
+	require.Equal(t, int32(1), even.calls.Load())
+
+	res, err = p.EnqueueWait(ctx, "8")
+	require.NoError(t, err)
+	require.Equal(t, "16", res)
+	// nonce is incremented on each hop
+	require.Equal(t, int64(2), lastNonce.Load())
+
+	require.NoError(t, p.Shutdown(ctx))
+}
+
+func TestPipeline_Stages(t *testing.T) {
+	errNegative := errors.New("negative")
+
+	inc := &funcService[int, int]{handle: func(e Event[int]) (int, error) {
+		if e.Data < 0 {
+			return 0, errNegative
+		}
+		return e.Data + 1, nil
+	}}
+	double := &funcService[int, int]{handle: func(e Event[int]) (int, error) {
+		return e.Data * 2, nil
+	}}
+	var lastNonce atomic.Int64
+	format := &funcService[int, string]{handle: func(e Event[int]) (string, error) {
+		lastNonce.Store(e.Nonce())
+		return strconv.Itoa(e.Data), nil
+	}}
+
+	r1 := New[int, int]()
+	r2 := New[int, string]()
+	r2.AddHandler("format", format, 1)
+	staged := NewStages(r1,
+		StageHandler[int]{ID: "inc", Service: inc, Workers: 2},
+		StageHandler[int]{ID: "double", Service: double, Workers: 2},
+	)
+	var results atomic.Int32
+	staged.AddCallback("results", &funcCallback[int]{handle: func(Event[int]) {
+		results.Add(1)
+	}}, 1)
+	p := Then(staged, r2, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	go func() {
+		_ = p.Start(ctx)
+	}()
+
+	res, err := staged.EnqueueWait(ctx, 3)
+	require.NoError(t, err)
+	require.Equal(t, 8, res)
+
+	res2, err := p.EnqueueWait(ctx, 4)
+	require.NoError(t, err)
+	require.Equal(t, "10", res2)
+	// nonce is incremented on each hop, within the reactor as well
+	require.Equal(t, int64(2), lastNonce.Load())
+
+	_, err = p.EnqueueWait(ctx, -1)
+	require.ErrorIs(t, err, errNegative)
+	// the stages after the error were skipped
+	require.Equal(t, int32(2), double.calls.Load())
+	// results of the staged pipeline are forwarded to the next reactor as well
+	require.Eventually(t, func() bool {
+		return format.calls.Load() == 2
+	}, time.Second, time.Millisecond)
+	// only the results of the last stage are passed to the callbacks
+	require.Eventually(t, func() bool {
+		return results.Load() == 3
+	}, time.Second, time.Millisecond)
+	require.Equal(t, int32(3), results.Load())
+
+	require.NoError(t, p.Shutdown(ctx))
+}
+
+func TestPipeline_EnqueueWaitNotStarted(t *testing.T) {
+	p := NewPipeline(New[string, int]())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	_, err := p.EnqueueWait(ctx, "1")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestPipeline_EnqueueWaitClock(t *testing.T) {
+	clk := clock.NewManual(time.Now())
+	timeout := time.Minute
+	p := NewPipeline(New(WithClock[string, int](clk), WithTimeout[string, int](timeout)))
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := p.EnqueueWait(context.Background(), "1")
+		errs <- err
+	}()
+	// waiting for the timeout of EnqueueWait
+	clk.BlockUntil(1)
+	clk.Advance(timeout)
+	require.ErrorIs(t, <-errs, context.DeadlineExceeded)
+}
@@ -4,13 +4,31 @@ import (
 	"bytes"
 	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
+	"runtime"
 	"sync/atomic"
 	"time"
 
 	"github.com/amirylm/go-options"
+	"github.com/amirylm/lockfree/clock"
+	"github.com/amirylm/lockfree/core"
+	"github.com/amirylm/lockfree/idle"
+	"github.com/amirylm/lockfree/queue"
+	"github.com/amirylm/lockfree/timingwheel"
+)
+
+// defaultTimeout is the default timeout of EnqueueWait
+const defaultTimeout = time.Second * 10
+
+var (
+	// ErrShutdown is returned by EnqueueWait once shutdown started
+	ErrShutdown = errors.New("reactor is shutting down")
+	// ErrDeadLetterQueueFull is passed to callbacks (along with the handler error)
+	// of events that exhausted their retries, and were dropped as the dead letter queue is full
+	ErrDeadLetterQueueFull = errors.New("dead letter queue is full")
 )
 
 type ReactiveService[T, C any] interface {
@@ -19,18 +37,58 @@ type ReactiveService[T, C any] interface {
 }
 
 type reactiveServiceAdapter[E, C any] struct {
+	id        string
 	svc       ReactiveService[E, C]
 	callbacks Demultiplexer[Event[C]]
+
+	retry       *RetryPolicy
+	deadLetters core.Queue[Event[E]]
+	timers      *timingwheel.TimingWheel
+	// pending is the number of handled events that their callback was not called yet
+	pending *atomic.Int64
 }
 
 func (adapter *reactiveServiceAdapter[T, C]) Select(e Event[T]) bool {
+	if len(e.handler) > 0 && e.handler != adapter.id {
+		// redriven events are handled only by the handler that failed
+		return false
+	}
 	return adapter.svc.Select(e)
 }
 
 func (adapter *reactiveServiceAdapter[T, C]) Handle(e Event[T]) {
-	n := e.nonce
+	adapter.pending.Add(1)
+	adapter.handle(e, e.nonce)
+}
+
+// handle calls the service, and retries in case of an error according to the retry policy.
+// The nonce of retried events is incremented on each attempt, while callbacks keep
+// the nonce of the original event (n) + 1.
+func (adapter *reactiveServiceAdapter[T, C]) handle(e Event[T], n int64) {
 	eid := e.ID
+	called := atomic.Bool{}
 	adapter.svc.Handle(e, func(data C, err error) {
+		if err != nil && adapter.retry != nil {
+			attempt := int(e.nonce-n) + 1
+			if attempt < adapter.retry.MaxAttempts {
+				retried := e
+				retried.nonce++
+				adapter.timers.AfterFunc(adapter.retry.Delay(attempt), func() {
+					// timer funcs must not block the timing wheel, therefore the handler runs on its own goroutine
+					go adapter.handle(retried, n)
+				})
+				return
+			}
+			dead := e
+			dead.Err = err
+			dead.handler = adapter.id
+			if !adapter.deadLetters.Enqueue(dead) {
+				err = fmt.Errorf("%w: %w", ErrDeadLetterQueueFull, err)
+			}
+		}
+		if called.CompareAndSwap(false, true) {
+			adapter.pending.Add(-1)
+		}
 		resp := Event[C]{
 			ID:    eid,
 			nonce: n + 1,
@@ -45,16 +103,42 @@ func (adapter *reactiveServiceAdapter[T, C]) Handle(e Event[T]) {
 
 type Reactor[E, C any] interface {
 	io.Closer
+	// Start starts the reactor and blocks until it stops, returns ErrAlreadyStarted if it is already running.
 	Start(pctx context.Context) error
+	// Shutdown stops accepting new events, and waits for queued events, in-flight handlers,
+	// pending callbacks and EnqueueWait callers to complete before stopping the reactor.
+	// In case the context is done before, the reactor is stopped and a *ShutdownError is returned.
+	// Returns ErrNotStarted if the reactor was never started.
+	Shutdown(context.Context) error
 
 	Enqueue(events ...E)
+	// EnqueueEvent enqueues an event while preserving its ID and nonce, used to forward events between reactors.
+	// Events with an error skip the handlers, and are passed to the callbacks with the error.
+	EnqueueEvent(Event[E])
 	EnqueueWait(context.Context, E) (C, error)
-
-	AddHandler(string, ReactiveService[E, C], int)
+	// EnqueueAfter enqueues the event once the given duration elapses.
+	EnqueueAfter(time.Duration, E) *timingwheel.Timer
+	// EnqueueAt enqueues the event at the given time.
+	EnqueueAt(time.Time, E) *timingwheel.Timer
+	// Every enqueues the event every time the given duration elapses, until the timer is stopped.
+	Every(time.Duration, E) *timingwheel.Timer
+
+	AddHandler(string, ReactiveService[E, C], int, ...options.Option[HandlerOptions[E, C]])
 	RemoveHandler(string)
 
-	AddCallback(string, Service[Event[C]], int)
+	AddCallback(string, Service[Event[C]], int, ...Middleware[Event[C]])
+	// AddCallbackSync is the same as AddCallback, and waits until the callback is registered.
+	AddCallbackSync(context.Context, string, Service[Event[C]], int, ...Middleware[Event[C]]) error
 	RemoveCallback(string)
+
+	// DeadLetters returns the queue of events that exhausted their retries.
+	// The nonce of dead events is incremented on each retry (i.e. attempts - 1 for new events),
+	// Err holds the last error and Handler returns the ID of the handler that failed.
+	// In case the queue is full, the event is dropped and its callback gets an error that wraps ErrDeadLetterQueueFull.
+	DeadLetters() core.Queue[Event[E]]
+	// Redrive moves up to n dead events (all in case n <= 0) back into the events queue,
+	// where they are handled only by the handler that failed. Returns the number of events that were moved.
+	Redrive(n int) int
 }
 
 // EventHandler is a function that handles events, it accepts a callback function as a second parameter.
@@ -73,41 +157,140 @@ func WithCallbacksDemux[T, C any](d Demultiplexer[Event[C]]) options.Option[reac
 	}
 }
 
+// WithTimes sets the timeout of EnqueueWait.
+//
+// Deprecated: tick is ignored, as EnqueueWait is notified once the result is ready. Use WithTimeout instead.
 func WithTimes[T, C any](tick, timeout time.Duration) options.Option[reactor[T, C]] {
+	return WithTimeout[T, C](timeout)
+}
+
+// WithTimeout sets the timeout of EnqueueWait.
+func WithTimeout[T, C any](timeout time.Duration) options.Option[reactor[T, C]] {
 	return func(r *reactor[T, C]) {
-		r.tick = tick
 		r.timeout = timeout
 	}
 }
 
+// WithHandlerMiddlewares adds middlewares that will wrap all handlers.
+func WithHandlerMiddlewares[T, C any](mws ...ReactiveMiddleware[T, C]) options.Option[reactor[T, C]] {
+	return func(r *reactor[T, C]) {
+		r.handlerMiddlewares = append(r.handlerMiddlewares, mws...)
+	}
+}
+
+// WithCallbackMiddlewares adds middlewares that will wrap all callback services.
+func WithCallbackMiddlewares[T, C any](mws ...Middleware[Event[C]]) options.Option[reactor[T, C]] {
+	return func(r *reactor[T, C]) {
+		r.callbackMiddlewares = append(r.callbackMiddlewares, mws...)
+	}
+}
+
+// WithDeadLetterQueue sets the queue of events that exhausted their retries, see WithRetry.
+func WithDeadLetterQueue[T, C any](q core.Queue[Event[T]]) options.Option[reactor[T, C]] {
+	return func(r *reactor[T, C]) {
+		r.deadLetters = q
+	}
+}
+
+// WithClock sets the clock that is used by the reactor, and by the default timing wheel.
+func WithClock[T, C any](c clock.Clock) options.Option[reactor[T, C]] {
+	return func(r *reactor[T, C]) {
+		r.clock = c
+	}
+}
+
+// WithTimingWheel sets the timing wheel that is used for delayed events and retries.
+func WithTimingWheel[T, C any](tw *timingwheel.TimingWheel) options.Option[reactor[T, C]] {
+	return func(r *reactor[T, C]) {
+		r.timers = tw
+	}
+}
+
+// WithIdleStrategies sets the idle strategy of the default events and callbacks demultiplexers.
+// The given function is called for each demultiplexer, as strategies must not be shared.
+func WithIdleStrategies[T, C any](newStrategy func() idle.Strategy) options.Option[reactor[T, C]] {
+	return func(r *reactor[T, C]) {
+		r.newIdleStrategy = newStrategy
+	}
+}
+
+// WithEventPartitionKey makes the events demultiplexer handle events with the same key sequentially,
+// while events with different keys are handled in parallel. See WithPartitionKey.
+// NOTE: not applicable when a custom events demultiplexer is provided.
+func WithEventPartitionKey[T, C any](f func(T) string) options.Option[reactor[T, C]] {
+	return func(r *reactor[T, C]) {
+		r.partitionKey = f
+	}
+}
+
 func New[T, C any](opts ...options.Option[reactor[T, C]]) Reactor[T, C] {
 	r := options.Apply(nil, opts...)
 
 	if r.events == nil {
-		r.events = NewDemux[Event[T]]()
+		var demuxOpts []options.Option[DemuxOptions[Event[T]]]
+		if r.newIdleStrategy != nil {
+			demuxOpts = append(demuxOpts, WithIdleStrategy[Event[T]](r.newIdleStrategy()))
+		}
+		if r.partitionKey != nil {
+			demuxOpts = append(demuxOpts, WithPartitionKey(func(e Event[T]) string {
+				return r.partitionKey(e.Data)
+			}))
+		}
+		r.events = NewDemux(demuxOpts...)
 	}
 	if r.callbacks == nil {
-		r.callbacks = NewDemux[Event[C]]()
-	}
-	if r.tick == 0 {
-		r.tick = time.Second / 2
+		var demuxOpts []options.Option[DemuxOptions[Event[C]]]
+		if r.newIdleStrategy != nil {
+			demuxOpts = append(demuxOpts, WithIdleStrategy[Event[C]](r.newIdleStrategy()))
+		}
+		r.callbacks = NewDemux(demuxOpts...)
 	}
 	if r.timeout == 0 {
-		r.timeout = time.Second * 10
+		r.timeout = defaultTimeout
+	}
+	if r.deadLetters == nil {
+		r.deadLetters = queue.New[Event[T]](core.WithCapacity(1024))
+	}
+	if r.clock == nil {
+		r.clock = clock.New()
+	}
+	if r.timers == nil {
+		r.timers = timingwheel.New(timingwheel.WithClock(r.clock))
 	}
 
 	return r
 }
 
 type reactor[T, C any] struct {
-	events        Demultiplexer[Event[T]]
-	callbacks     Demultiplexer[Event[C]]
-	tick, timeout time.Duration
+	events       Demultiplexer[Event[T]]
+	callbacks    Demultiplexer[Event[C]]
+	timeout      time.Duration
+	partitionKey func(T) string
+
+	newIdleStrategy func() idle.Strategy
+
+	handlerMiddlewares  []ReactiveMiddleware[T, C]
+	callbackMiddlewares []Middleware[Event[C]]
+
+	deadLetters core.Queue[Event[T]]
+	timers      *timingwheel.TimingWheel
+	clock       clock.Clock
 
 	done atomic.Pointer[context.CancelFunc]
+
+	// draining is set once shutdown started
+	draining atomic.Bool
+	// pending is the number of handled events that their callback was not called yet
+	pending atomic.Int64
+	// waiters is the number of EnqueueWait callers that are waiting for results
+	waiters atomic.Int64
 }
 
 func (r *reactor[T, C]) genID(T) ID {
+	return newID()
+}
+
+func newID() ID {
 	return []byte(fmt.Sprintf("%04d-%08d-%04d",
 		rand.Intn(9999), rand.Intn(99999999), rand.Intn(9999)))
 }
@@ -115,11 +298,74 @@ func (r *reactor[T, C]) genID(T) ID {
 func (r *reactor[T, C]) Start(pctx context.Context) error {
 	ctx, cancel := context.WithCancel(pctx)
 	defer cancel()
-	r.done.Store(&cancel)
+	if !r.done.CompareAndSwap(nil, &cancel) {
+		return ErrAlreadyStarted
+	}
+	defer r.done.CompareAndSwap(&cancel, nil)
+	r.draining.Store(false)
 	go func() {
 		_ = r.callbacks.Start(ctx)
 	}()
-	return r.events.Start(ctx)
+	go func() {
+		_ = r.timers.Start(ctx)
+	}()
+	if err := r.events.Start(ctx); err != nil {
+		return err
+	}
+	// events were drained, waiting for shutdown to complete
+	<-ctx.Done()
+	return nil
+}
+
+func (r *reactor[T, C]) Shutdown(ctx context.Context) error {
+	if r.events.State() == StateCreated {
+		return ErrNotStarted
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	r.draining.Store(true)
+
+	err := r.events.Shutdown(ctx)
+	if err == nil {
+		err = r.await(ctx, func() bool {
+			return r.pending.Load() == 0
+		})
+	}
+	if err == nil {
+		// waiters get their results once the callbacks are drained
+		err = r.callbacks.Shutdown(ctx)
+		if errors.Is(err, ErrNotStarted) {
+			// callbacks loop didn't start yet, nothing to drain
+			err = nil
+		}
+	}
+	if err == nil {
+		err = r.await(ctx, func() bool {
+			return r.waiters.Load() == 0
+		})
+	}
+	if err == nil {
+		return nil
+	}
+	var serr *ShutdownError
+	if !errors.As(err, &serr) {
+		serr = &ShutdownError{Err: err}
+	}
+	serr.Callbacks = int(r.pending.Load())
+	serr.Waiters = int(r.waiters.Load())
+	return serr
+}
+
+// await polls the given condition until it is met or the context is done
+func (r *reactor[T, C]) await(ctx context.Context, cond func() bool) error {
+	for !cond() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		runtime.Gosched()
+	}
+	return nil
 }
 
 func (r *reactor[T, C]) Close() error {
@@ -133,6 +379,9 @@ func (r *reactor[T, C]) Close() error {
 }
 
 func (r *reactor[T, C]) Enqueue(events ...T) {
+	if r.draining.Load() {
+		return
+	}
 	for _, data := range events {
 		r.events.Enqueue(Event[T]{
 			ID:    r.genID(data),
@@ -142,8 +391,53 @@ func (r *reactor[T, C]) Enqueue(events ...T) {
 	}
 }
 
+func (r *reactor[T, C]) EnqueueEvent(e Event[T]) {
+	if r.draining.Load() {
+		return
+	}
+	if e.Err != nil {
+		r.callbacks.Enqueue(Event[C]{
+			ID:    e.ID,
+			nonce: e.nonce + 1,
+			Err:   e.Err,
+		})
+		return
+	}
+	r.events.Enqueue(e)
+}
+
+func (r *reactor[T, C]) EnqueueAfter(d time.Duration, data T) *timingwheel.Timer {
+	return r.timers.AfterFunc(d, func() {
+		r.Enqueue(data)
+	})
+}
+
+func (r *reactor[T, C]) EnqueueAt(t time.Time, data T) *timingwheel.Timer {
+	return r.timers.At(t, func() {
+		r.Enqueue(data)
+	})
+}
+
+func (r *reactor[T, C]) Every(d time.Duration, data T) *timingwheel.Timer {
+	return r.timers.Every(d, func() {
+		r.Enqueue(data)
+	})
+}
+
+// waitContext returns the context of EnqueueWait, that is done once the timeout has passed on the reactor's clock
+func (r *reactor[T, C]) waitContext(pctx context.Context) (context.Context, context.CancelFunc) {
+	return r.clock.WithTimeout(pctx, r.timeout)
+}
+
 func (r *reactor[T, C]) EnqueueWait(pctx context.Context, data T) (C, error) {
-	ctx, cancel := context.WithTimeout(pctx, r.timeout)
+	if r.draining.Load() {
+		var res C
+		return res, ErrShutdown
+	}
+	r.waiters.Add(1)
+	defer r.waiters.Add(-1)
+
+	ctx, cancel := r.waitContext(pctx)
 	defer cancel()
 
 	resultp := &atomic.Pointer[Event[C]]{}
@@ -155,8 +449,12 @@ func (r *reactor[T, C]) EnqueueWait(pctx context.Context, data T) (C, error) {
 		id:     id,
 		nonce:  int64(1),
 		result: resultp,
+		done:   make(chan struct{}),
+	}
+	if err := r.callbacks.RegisterSync(ctx, cid, svc, 1); err != nil {
+		var res C
+		return res, err
 	}
-	r.callbacks.Register(cid, svc, 1)
 	defer r.callbacks.Unregister(cid)
 
 	r.events.Enqueue(Event[T]{
@@ -165,11 +463,11 @@ func (r *reactor[T, C]) EnqueueWait(pctx context.Context, data T) (C, error) {
 		Data:  data,
 	})
 
-	result := resultp.Load()
-	for ctx.Err() == nil && result == nil {
-		time.Sleep(r.tick)
-		result = resultp.Load()
+	select {
+	case <-svc.done:
+	case <-ctx.Done():
 	}
+	result := resultp.Load()
 
 	if result != nil {
 		return result.Data, result.Err
@@ -178,25 +476,65 @@ func (r *reactor[T, C]) EnqueueWait(pctx context.Context, data T) (C, error) {
 	return res, ctx.Err()
 }
 
-func (r *reactor[T, C]) AddHandler(id string, svc ReactiveService[T, C], workers int) {
-	r.events.Register(id, &reactiveServiceAdapter[T, C]{
+func (r *reactor[T, C]) AddHandler(id string, svc ReactiveService[T, C], workers int, opts ...options.Option[HandlerOptions[T, C]]) {
+	o := options.Apply(nil, opts...)
+	svc = ChainReactive(ChainReactive(svc, o.middlewares...), r.handlerMiddlewares...)
+	adapter := &reactiveServiceAdapter[T, C]{
+		id:        id,
 		svc:       svc,
 		callbacks: r.callbacks,
-	}, workers)
+		pending:   &r.pending,
+	}
+	if o.retry != nil {
+		// retries are running on their own goroutines outside of the demultiplexer (worker limits don't apply),
+		// therefore panics are recovered and treated as errors
+		adapter.svc = Recoverer[T, C]()(svc)
+		adapter.retry = o.retry
+		adapter.deadLetters = r.deadLetters
+		adapter.timers = r.timers
+	}
+	r.events.Register(id, adapter, workers)
 }
 
 func (r *reactor[T, C]) RemoveHandler(id string) {
 	r.events.Unregister(id)
 }
 
-func (r *reactor[T, C]) AddCallback(id string, svc Service[Event[C]], workers int) {
+func (r *reactor[T, C]) AddCallback(id string, svc Service[Event[C]], workers int, mws ...Middleware[Event[C]]) {
+	svc = Chain(Chain(svc, mws...), r.callbackMiddlewares...)
 	r.callbacks.Register(id, svc, workers)
 }
 
+func (r *reactor[T, C]) AddCallbackSync(ctx context.Context, id string, svc Service[Event[C]], workers int, mws ...Middleware[Event[C]]) error {
+	svc = Chain(Chain(svc, mws...), r.callbackMiddlewares...)
+	return r.callbacks.RegisterSync(ctx, id, svc, workers)
+}
+
 func (r *reactor[T, C]) RemoveCallback(id string) {
 	r.callbacks.Unregister(id)
 }
 
+func (r *reactor[T, C]) DeadLetters() core.Queue[Event[T]] {
+	return r.deadLetters
+}
+
+func (r *reactor[T, C]) Redrive(n int) int {
+	if n <= 0 {
+		n = r.deadLetters.Size()
+	}
+	i := 0
+	for ; i < n; i++ {
+		e, ok := r.deadLetters.Dequeue()
+		if !ok {
+			break
+		}
+		e.nonce = 0
+		e.Err = nil
+		r.events.Enqueue(e)
+	}
+	return i
+}
+
 // ID is the ID used for events
 type ID []byte
 
@@ -217,17 +555,26 @@ type Event[T any] struct {
 	nonce int64
 	Data  T
 	Err   error
+	// handler is the ID of the handler that failed, set for dead events
+	handler string
 }
 
 func (e Event[T]) Nonce() int64 {
 	return e.nonce
 }
 
+// Handler returns the ID of the handler that exhausted its retries, set only for dead events.
+func (e Event[T]) Handler() string {
+	return e.handler
+}
+
 type waitCallbackService[C any] struct {
 	id    ID
 	nonce int64
 
 	result *atomic.Pointer[Event[C]]
+	// done is closed once the result is ready
+	done chan struct{}
 }
 
 func (c *waitCallbackService[C]) Select(e Event[C]) bool {
@@ -235,5 +582,7 @@ func (c *waitCallbackService[C]) Select(e Event[C]) bool {
 }
 
 func (c *waitCallbackService[C]) Handle(e Event[C]) {
-	c.result.Store(&e)
+	if c.result.CompareAndSwap(nil, &e) {
+		close(c.done)
+	}
 }
@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"runtime"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/amirylm/lockfree/clock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -50,7 +53,7 @@ func TestReactor_NewWithArgs(t *testing.T) {
 	r := New(
 		WithCallbacksDemux[mockEventData](NewDemux[Event[mockEventData]]()),
 		WithEventsDemux[mockEventData, mockEventData](NewDemux[Event[mockEventData]]()),
-		WithTimes[mockEventData, mockEventData](time.Second, time.Minute),
+		WithTimeout[mockEventData, mockEventData](time.Minute),
 	)
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*5)
@@ -69,7 +72,11 @@ func TestReactor_CallbackTimeout(t *testing.T) {
 	defer pcancel()
 
 	timeout := time.Second
-	r := New(WithTimes[mockEventData, mockEventData](time.Second/4, timeout))
+	clk := clock.NewManual(time.Now())
+	r := New(
+		WithTimeout[mockEventData, mockEventData](timeout),
+		WithClock[mockEventData, mockEventData](clk),
+	)
 	rs := &ReactiveServiceImpl{
 		SelectLogic: func(e Event[mockEventData]) bool {
 			return true
@@ -88,18 +95,31 @@ func TestReactor_CallbackTimeout(t *testing.T) {
 	r.AddHandler("test-1", rs, 1)
 	defer r.RemoveHandler("test-1")
 
-	res, err := r.EnqueueWait(pctx, mockEventData{
-		name: "hello timeout",
-	})
-	require.Error(t, err)
-	require.Equal(t, res, mockEventData{})
+	type result struct {
+		res mockEventData
+		err error
+	}
+	results := make(chan result, 1)
+	go func() {
+		res, err := r.EnqueueWait(pctx, mockEventData{
+			name: "hello timeout",
+		})
+		results <- result{res, err}
+	}()
+	// waiting for the timeout of EnqueueWait
+	clk.BlockUntil(1)
+	clk.Advance(timeout)
+
+	res := <-results
+	require.ErrorIs(t, res.err, context.DeadlineExceeded)
+	require.Equal(t, res.res, mockEventData{})
 }
 
 func TestReactor_Sanity(t *testing.T) {
 	pctx, pcancel := context.WithCancel(context.Background())
 	defer pcancel()
 
-	r := New(WithTimes[mockEventData, mockEventData](time.Second/4, time.Second*2))
+	r := New(WithTimeout[mockEventData, mockEventData](time.Second * 2))
 	rs := &ReactiveServiceImpl{
 		SelectLogic: func(e Event[mockEventData]) bool {
 			return len(e.Data.name) > 0 && e.Data.name != "errored"
@@ -192,3 +212,180 @@ func TestReactor_EventID(t *testing.T) {
 	require.Equal(t, ID{}, IDFromString(""), "empty id encoding failed")
 	require.Equal(t, ID(nil), IDFromString("`^"), "invalid id encoding failed")
 }
+
+func TestReactor_EventPartitionKey(t *testing.T) {
+	pctx, pcancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer pcancel()
+
+	r := New(WithEventPartitionKey[mockEventData, mockEventData](func(e mockEventData) string {
+		return e.name
+	}))
+	go func() {
+		_ = r.Start(pctx)
+	}()
+	defer func() {
+		_ = r.Close()
+	}()
+
+	var last, handled, errs atomic.Int32
+	r.AddHandler("ordered", &ReactiveServiceImpl{
+		SelectLogic: func(e Event[mockEventData]) bool {
+			return true
+		},
+		HandleLogic: func(e Event[mockEventData], callback func(mockEventData, error)) {
+			if !last.CompareAndSwap(e.Data.Count-1, e.Data.Count) {
+				errs.Add(1)
+			}
+			handled.Add(1)
+		},
+	}, 4)
+
+	n := int32(100)
+	for i := int32(1); i <= n; i++ {
+		r.Enqueue(mockEventData{name: "ordered", Count: i})
+	}
+	for handled.Load() < n && pctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	require.Equal(t, n, handled.Load())
+	require.Equal(t, int32(0), errs.Load(), "events were handled out of order")
+}
+
+func TestReactor_Timers(t *testing.T) {
+	pctx, pcancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer pcancel()
+
+	clk := clock.NewManual(time.Now())
+	r := New(WithClock[mockEventData, mockEventData](clk))
+	go func() {
+		_ = r.Start(pctx)
+	}()
+	defer func() {
+		_ = r.Close()
+	}()
+
+	var mu sync.Mutex
+	handled := map[string]int{}
+	r.AddHandler("timers", &ReactiveServiceImpl{
+		SelectLogic: func(e Event[mockEventData]) bool {
+			return true
+		},
+		HandleLogic: func(e Event[mockEventData], callback func(mockEventData, error)) {
+			mu.Lock()
+			defer mu.Unlock()
+			handled[e.Data.name]++
+		},
+	}, 1)
+	count := func(name string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled[name]
+	}
+	waitCount := func(name string, expected int) {
+		for count(name) < expected && pctx.Err() == nil {
+			time.Sleep(time.Millisecond)
+		}
+		require.Equal(t, expected, count(name), name)
+	}
+
+	r.EnqueueAfter(time.Second, mockEventData{name: "after"})
+	r.EnqueueAt(clk.Now().Add(time.Second*2), mockEventData{name: "at"})
+	every := r.Every(time.Second, mockEventData{name: "every"})
+	stopped := r.EnqueueAfter(time.Second, mockEventData{name: "stopped"})
+	require.True(t, stopped.Stop())
+
+	// waiting for the timing wheel ticker
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	waitCount("after", 1)
+	waitCount("every", 1)
+	require.Equal(t, 0, count("at"))
+
+	clk.Advance(time.Second)
+	waitCount("at", 1)
+	waitCount("every", 2)
+
+	require.True(t, every.Stop())
+	clk.Advance(time.Second * 5)
+	<-time.After(time.Millisecond * 10)
+	require.Equal(t, 2, count("every"))
+	require.Equal(t, 1, count("after"))
+	require.Equal(t, 0, count("stopped"))
+}
+
+func TestReactor_Shutdown(t *testing.T) {
+	t.Run("drain", func(t *testing.T) {
+		r := New[mockEventData, mockEventData]()
+		started := make(chan error, 1)
+		go func() {
+			started <- r.Start(context.Background())
+		}()
+
+		var handled atomic.Int32
+		r.AddHandler("delayed", &ReactiveServiceImpl{
+			SelectLogic: func(e Event[mockEventData]) bool {
+				return true
+			},
+			HandleLogic: func(e Event[mockEventData], callback func(mockEventData, error)) {
+				go func() {
+					time.Sleep(time.Millisecond * 5)
+					handled.Add(1)
+					e.Data.Count++
+					callback(e.Data, nil)
+				}()
+			},
+		}, 4)
+
+		n := int32(20)
+		for i := int32(0); i < n; i++ {
+			r.Enqueue(mockEventData{name: "event"})
+		}
+		results := make(chan error, 1)
+		go func() {
+			res, err := r.EnqueueWait(context.Background(), mockEventData{name: "wait"})
+			if err == nil && res.Count != 1 {
+				err = fmt.Errorf("unexpected result %+v", res)
+			}
+			results <- err
+		}()
+		for r.(*reactor[mockEventData, mockEventData]).waiters.Load() == 0 {
+			runtime.Gosched()
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		require.NoError(t, r.Shutdown(ctx))
+		require.NoError(t, <-started)
+		require.NoError(t, <-results)
+		require.Equal(t, n+1, handled.Load())
+
+		_, err := r.EnqueueWait(context.Background(), mockEventData{name: "rejected"})
+		require.ErrorIs(t, err, ErrShutdown)
+	})
+
+	t.Run("missing callbacks", func(t *testing.T) {
+		r := New[mockEventData, mockEventData]()
+		go func() {
+			_ = r.Start(context.Background())
+		}()
+
+		r.AddHandler("no-callback", &ReactiveServiceImpl{
+			SelectLogic: func(e Event[mockEventData]) bool {
+				return true
+			},
+			HandleLogic: func(e Event[mockEventData], callback func(mockEventData, error)) {},
+		}, 1)
+		r.Enqueue(mockEventData{name: "event"})
+		for r.(*reactor[mockEventData, mockEventData]).pending.Load() == 0 {
+			runtime.Gosched()
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+		defer cancel()
+		err := r.Shutdown(ctx)
+		var serr *ShutdownError
+		require.ErrorAs(t, err, &serr)
+		require.Equal(t, 1, serr.Callbacks)
+		require.Contains(t, err.Error(), "1 pending callbacks")
+	})
+}
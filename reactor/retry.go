@@ -0,0 +1,41 @@
+package reactor
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/amirylm/go-options"
+)
+
+// RetryPolicy configures retries of handlers that pass an error to their callback.
+// Events that exhaust their attempts are moved to the dead letter queue of the reactor.
+type RetryPolicy struct {
+	// MaxAttempts is the max number of times the handler is called for an event, including the first call.
+	MaxAttempts int
+	// Backoff is the delay before the first retry, it is doubled on each retry.
+	Backoff time.Duration
+	// MaxBackoff caps the delay between retries, zero means no cap.
+	MaxBackoff time.Duration
+	// Jitter is the fraction of the delay that is randomized, e.g. 0.2 means ±20%.
+	Jitter float64
+}
+
+// Delay returns the delay before the given attempt (1 is the first retry).
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	d := float64(p.Backoff) * math.Pow(2, float64(attempt-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (rand.Float64()*2 - 1)
+	}
+	return time.Duration(d)
+}
+
+// WithRetry sets the retry policy of a specific handler.
+func WithRetry[T, C any](policy RetryPolicy) options.Option[HandlerOptions[T, C]] {
+	return func(o *HandlerOptions[T, C]) {
+		o.retry = &policy
+	}
+}
@@ -0,0 +1,276 @@
+package reactor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/amirylm/lockfree/core"
+	"github.com/amirylm/lockfree/queue"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		attempt  int
+		min, max time.Duration
+	}{
+		{
+			name:    "first retry",
+			policy:  RetryPolicy{Backoff: time.Millisecond * 10},
+			attempt: 1,
+			min:     time.Millisecond * 10,
+			max:     time.Millisecond * 10,
+		},
+		{
+			name:    "exponential",
+			policy:  RetryPolicy{Backoff: time.Millisecond * 10},
+			attempt: 4,
+			min:     time.Millisecond * 80,
+			max:     time.Millisecond * 80,
+		},
+		{
+			name:    "capped",
+			policy:  RetryPolicy{Backoff: time.Millisecond * 10, MaxBackoff: time.Millisecond * 50},
+			attempt: 10,
+			min:     time.Millisecond * 50,
+			max:     time.Millisecond * 50,
+		},
+		{
+			name:    "jitter",
+			policy:  RetryPolicy{Backoff: time.Millisecond * 100, Jitter: 0.2},
+			attempt: 1,
+			min:     time.Millisecond * 80,
+			max:     time.Millisecond * 120,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for i := 0; i < 10; i++ {
+				d := tc.policy.Delay(tc.attempt)
+				require.GreaterOrEqual(t, d, tc.min)
+				require.LessOrEqual(t, d, tc.max)
+			}
+		})
+	}
+}
+
+func TestReactor_Retry(t *testing.T) {
+	pctx, pcancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer pcancel()
+
+	r := New(WithTimeout[mockEventData, mockEventData](time.Second * 2))
+	go func() {
+		_ = r.Start(pctx)
+	}()
+	defer func() {
+		_ = r.Close()
+	}()
+
+	var attempts atomic.Int32
+	var nonces []int64
+	r.AddHandler("flaky", &ReactiveServiceImpl{
+		SelectLogic: func(e Event[mockEventData]) bool {
+			return true
+		},
+		HandleLogic: func(e Event[mockEventData], callback func(mockEventData, error)) {
+			nonces = append(nonces, e.Nonce())
+			if attempts.Add(1) < 3 {
+				callback(e.Data, errors.New("test-error"))
+				return
+			}
+			e.Data.Count = attempts.Load()
+			callback(e.Data, nil)
+		},
+	}, 1, WithRetry[mockEventData, mockEventData](RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+	}))
+
+	res, err := r.EnqueueWait(pctx, mockEventData{name: "flaky"})
+	require.NoError(t, err)
+	require.Equal(t, int32(3), res.Count)
+	require.Equal(t, []int64{0, 1, 2}, nonces)
+	require.True(t, r.DeadLetters().Empty())
+}
+
+func TestReactor_DeadLetters(t *testing.T) {
+	pctx, pcancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer pcancel()
+
+	r := New(WithTimeout[mockEventData, mockEventData](time.Second * 2))
+	go func() {
+		_ = r.Start(pctx)
+	}()
+	defer func() {
+		_ = r.Close()
+	}()
+
+	var attempts atomic.Int32
+	r.AddHandler("failing", &ReactiveServiceImpl{
+		SelectLogic: func(e Event[mockEventData]) bool {
+			return true
+		},
+		HandleLogic: func(e Event[mockEventData], callback func(mockEventData, error)) {
+			attempts.Add(1)
+			if e.Data.name == "panic" {
+				panic("test-panic")
+			}
+			callback(e.Data, errors.New("test-error"))
+		},
+	}, 1, WithRetry[mockEventData, mockEventData](RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+	}))
+
+	_, err := r.EnqueueWait(pctx, mockEventData{name: "dead"})
+	require.Error(t, err)
+	require.Equal(t, int32(3), attempts.Load())
+
+	dlq := r.DeadLetters()
+	require.Equal(t, 1, dlq.Size())
+	dead, ok := dlq.Dequeue()
+	require.True(t, ok)
+	// the nonce is incremented on each retry
+	require.Equal(t, int64(2), dead.Nonce())
+	require.Equal(t, "failing", dead.Handler())
+	require.EqualError(t, dead.Err, "test-error")
+	require.Equal(t, "dead", dead.Data.name)
+	require.True(t, dlq.Enqueue(dead))
+
+	require.Equal(t, 1, r.Redrive(0))
+	for attempts.Load() < 6 && pctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	require.Equal(t, int32(6), attempts.Load())
+	for dlq.Empty() && pctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	dead, ok = dlq.Dequeue()
+	require.True(t, ok)
+	require.Equal(t, int64(2), dead.Nonce())
+
+	// panics are treated as errors
+	_, err = r.EnqueueWait(pctx, mockEventData{name: "panic"})
+	require.ErrorIs(t, err, ErrPanic)
+	require.Equal(t, int32(9), attempts.Load())
+	dead, ok = dlq.Dequeue()
+	require.True(t, ok)
+	require.ErrorIs(t, dead.Err, ErrPanic)
+}
+
+func TestReactor_Redrive(t *testing.T) {
+	pctx, pcancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer pcancel()
+
+	r := New(WithDeadLetterQueue[mockEventData, mockEventData](queue.New[Event[mockEventData]](core.WithCapacity(1))))
+	go func() {
+		_ = r.Start(pctx)
+	}()
+	defer func() {
+		_ = r.Close()
+	}()
+
+	var failed, succeeded atomic.Int32
+	r.AddHandler("failing", &ReactiveServiceImpl{
+		SelectLogic: func(e Event[mockEventData]) bool {
+			return true
+		},
+		HandleLogic: func(e Event[mockEventData], callback func(mockEventData, error)) {
+			failed.Add(1)
+			callback(e.Data, errors.New("test-error"))
+		},
+	}, 1, WithRetry[mockEventData, mockEventData](RetryPolicy{MaxAttempts: 1}))
+	r.AddHandler("succeeding", &ReactiveServiceImpl{
+		SelectLogic: func(e Event[mockEventData]) bool {
+			return true
+		},
+		HandleLogic: func(e Event[mockEventData], callback func(mockEventData, error)) {
+			succeeded.Add(1)
+			callback(e.Data, nil)
+		},
+	}, 1)
+
+	r.Enqueue(mockEventData{name: "dead"})
+	for (r.DeadLetters().Empty() || succeeded.Load() == 0) && pctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	require.Equal(t, int32(1), succeeded.Load())
+
+	// redriven events are handled only by the failing handler
+	require.Equal(t, 1, r.Redrive(0))
+	for failed.Load() < 2 && pctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	for r.DeadLetters().Empty() && pctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	require.Equal(t, int32(2), failed.Load())
+	require.Equal(t, int32(1), succeeded.Load())
+
+	// the dead letter queue is full
+	r.RemoveHandler("succeeding")
+	_, err := r.EnqueueWait(pctx, mockEventData{name: "dropped"})
+	require.ErrorIs(t, err, ErrDeadLetterQueueFull)
+	require.ErrorContains(t, err, "test-error")
+	require.Equal(t, 1, r.DeadLetters().Size())
+}
+
+func TestReactor_RetryDoesNotBlockTimers(t *testing.T) {
+	pctx, pcancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer pcancel()
+
+	r := New[mockEventData, mockEventData]()
+	go func() {
+		_ = r.Start(pctx)
+	}()
+	defer func() {
+		_ = r.Close()
+	}()
+
+	unblock := make(chan struct{})
+	var retrying atomic.Bool
+	r.AddHandler("slow", &ReactiveServiceImpl{
+		SelectLogic: func(e Event[mockEventData]) bool {
+			return e.Data.name == "slow"
+		},
+		HandleLogic: func(e Event[mockEventData], callback func(mockEventData, error)) {
+			if e.Nonce() == 0 {
+				callback(e.Data, errors.New("test-error"))
+				return
+			}
+			retrying.Store(true)
+			<-unblock
+			callback(e.Data, nil)
+		},
+	}, 1, WithRetry[mockEventData, mockEventData](RetryPolicy{
+		MaxAttempts: 2,
+		Backoff:     time.Millisecond,
+	}))
+	r.AddHandler("timer", &ReactiveServiceImpl{
+		SelectLogic: func(e Event[mockEventData]) bool {
+			return e.Data.name == "timer"
+		},
+		HandleLogic: func(e Event[mockEventData], callback func(mockEventData, error)) {
+			close(unblock)
+			callback(e.Data, nil)
+		},
+	}, 1)
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := r.EnqueueWait(pctx, mockEventData{name: "slow"})
+		errs <- err
+	}()
+	for !retrying.Load() && pctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	// the timer fires while the retry is blocked
+	r.EnqueueAfter(time.Millisecond, mockEventData{name: "timer"})
+	require.NoError(t, <-errs)
+}
@@ -1,100 +1,251 @@
 package ringbuffer
 
 import (
+	"fmt"
+	"math"
+	"runtime"
+	"slices"
 	"sync/atomic"
 
 	"github.com/amirylm/go-options"
 	"github.com/amirylm/lockfree/core"
+	"github.com/amirylm/lockfree/internal/pad"
+	"github.com/amirylm/lockfree/internal/shard"
 )
 
-// New creates a new RingBuffer
+// defaultCapacity is the capacity of ring buffers that were created without a (valid) capacity
+const defaultCapacity = 1024
+
+// New creates a new RingBuffer, the capacity defaults to 1024.
+// Panics if the eviction callback doesn't match the value type, see core.WithOnEvict.
 func New[Value any](opts ...options.Option[core.Options]) core.Queue[Value] {
-	o := options.Apply(nil, opts...)
+	return newRingBuffer[Value](options.Apply(nil, opts...))
+}
 
+// NewOverwrite creates a new RingBuffer in override mode, where writes to a full buffer evict the oldest item.
+// Use EnqueueOverwrite to get the evicted items, or core.WithOnEvict to get notified.
+func NewOverwrite[Value any](opts ...options.Option[core.Options]) *RingBuffer[Value] {
+	return newRingBuffer[Value](options.Apply(nil, append(slices.Clone(opts), core.WithOverride(true))...))
+}
+
+func newRingBuffer[Value any](o *core.Options) *RingBuffer[Value] {
+	capacity := uint64(defaultCapacity)
+	if c := o.Capacity(); c > 0 {
+		capacity = uint64(c)
+	}
 	rb := &RingBuffer[Value]{
-		state:    atomic.Uint64{},
-		capacity: uint32(o.Capacity()),
+		capacity: capacity,
+		// the largest multiple of the capacity that fits in 32 bits
+		wrap:     (math.MaxUint32 + 1) / capacity * capacity,
 		override: o.Override(),
 	}
+	if f := o.OnEvict(); f != nil {
+		onEvict, ok := f.(func(Value))
+		if !ok {
+			panic(fmt.Sprintf("ringbuffer: eviction callback of type %T doesn't match %T", f, onEvict))
+		}
+		rb.onEvict = onEvict
+	}
 
-	rb.elements = make([]*atomic.Pointer[Value], rb.capacity)
-
-	rb.state.Store(new(ringBufferState).Uint64())
-
+	// slots are stored contiguously, rather than a heap object per slot
+	rb.elements = make([]slot[Value], capacity)
 	for i := range rb.elements {
-		rb.elements[i] = &atomic.Pointer[Value]{}
+		rb.elements[i].seq.Store(uint64(i))
 	}
 
 	return rb
 }
 
 // RingBuffer is a lock-free queue implementation based on a ring buffer.
+// Head and tail are packed into a single word, so a write can override the oldest element
+// by advancing both in one CAS. Positions are claimed with CAS on the state, while each slot
+// has a sequence number to let readers and writers of a claimed position wait for each other.
+// The state is padded, so CAS operations by producers and consumers are not
+// invalidating the read-only fields or neighbouring objects.
 type RingBuffer[Value any] struct {
-	elements []*atomic.Pointer[Value]
-
-	capacity uint32
-	state    atomic.Uint64
-
+	_     pad.CacheLine
+	state atomic.Uint64
+	_     pad.CacheLine
+
+	elements []slot[Value]
+	capacity uint64
+	// wrap is the modulo of positions, a multiple of the capacity so positions keep mapping to the same slots
+	wrap     uint64
 	override bool
+	onEvict  func(Value)
 }
 
 func (rb *RingBuffer[Value]) Empty() bool {
-	return newState(rb.state.Load()).Empty()
+	return rb.Size() == 0
 }
 
 func (rb *RingBuffer[Value]) Full() bool {
-	return newState(rb.state.Load()).Full()
+	return rb.Size() == int(rb.capacity)
 }
 
 func (rb *RingBuffer[Value]) Size() int {
-	state := newState(rb.state.Load())
-	if state.full {
-		return int(rb.capacity)
-	}
-	return int(state.tail - state.head)
+	return rb.size(newState(rb.state.Load()))
 }
 
-// Push adds a new item to the buffer.
-// We revert changes and retry in case of some conflict with other goroutine.
+// Enqueue adds a new item to the buffer.
+// Returns false if the buffer is full (or the next slot is still read), unless override is set
+// and then the oldest item is evicted.
 func (rb *RingBuffer[Value]) Enqueue(v Value) bool {
-	originalState := rb.state.Load()
-	state := newState(originalState)
-	if state.full {
-		if !rb.override {
+	if rb.override {
+		_, _ = rb.EnqueueOverwrite(v)
+		return true
+	}
+	for {
+		originalState := rb.state.Load()
+		state := newState(originalState)
+		if rb.size(state) == int(rb.capacity) {
+			return false
+		}
+		pos := state.tail
+		if !rb.ready(pos, pos) {
+			if rb.state.Load() != originalState {
+				continue
+			}
+			// the slot is still read by the previous lap
 			return false
 		}
-		// in case we override items
-		_, _ = rb.Dequeue()
+		state.tail = rb.add(pos, 1)
+		if rb.state.CompareAndSwap(originalState, state.Uint64()) {
+			rb.write(pos, v)
+			return true
+		}
 	}
-	el := rb.elements[state.tail%rb.capacity]
-	state.tail++
-	state.full = state.tail%rb.capacity == state.head%rb.capacity
-	if rb.state.CompareAndSwap(originalState, state.Uint64()) {
-		el.Store(&v)
-		return true
+}
+
+// EnqueueOverwrite adds a new item to the buffer, and evicts the oldest item in case the buffer is full.
+// The evicted item is returned, and also passed to the eviction callback if provided.
+// Every write evicts at most one item, as head and tail are advanced together.
+func (rb *RingBuffer[Value]) EnqueueOverwrite(v Value) (evicted Value, didEvict bool) {
+	for {
+		originalState := rb.state.Load()
+		state := newState(originalState)
+		full := rb.size(state) == int(rb.capacity)
+		pos, head := state.tail, state.head
+		// a full buffer requires the oldest item to be written, otherwise the slot must be read
+		seq := pos
+		if full {
+			seq = rb.add(head, 1)
+		}
+		if !rb.ready(pos, seq) {
+			// the slot is used by an operation of the previous lap
+			runtime.Gosched()
+			continue
+		}
+		state.tail = rb.add(pos, 1)
+		if full {
+			state.head = rb.add(head, 1)
+		}
+		if !rb.state.CompareAndSwap(originalState, state.Uint64()) {
+			continue
+		}
+		if !full {
+			rb.write(pos, v)
+			return evicted, false
+		}
+		// the position of the oldest item is mapped to the same slot
+		evicted = rb.replace(pos, head, v)
+		if rb.onEvict != nil {
+			rb.onEvict(evicted)
+		}
+		return evicted, true
 	}
-	return rb.Enqueue(v)
 }
 
-// Pop reads the next item in the buffer.
-// We retry in case of some conflict with other goroutine.
+// Dequeue reads the next item in the buffer.
+// Returns false if the buffer is empty (or the next item is still written).
 func (rb *RingBuffer[Value]) Dequeue() (Value, bool) {
-	originalState := rb.state.Load()
-	state := newState(originalState)
-	var v Value
-	if state.Empty() {
-		return v, false
-	}
-	el := rb.elements[state.head%rb.capacity]
-	state.head++
-	state.full = false
-	val := el.Load()
-	if rb.state.CompareAndSwap(originalState, state.Uint64()) {
-		if val != nil {
-			v = *val
+	for {
+		originalState := rb.state.Load()
+		state := newState(originalState)
+		if rb.size(state) == 0 {
+			var v Value
+			return v, false
+		}
+		pos := state.head
+		if !rb.ready(pos, rb.add(pos, 1)) {
+			if rb.state.Load() != originalState {
+				continue
+			}
+			// the slot is still written
+			var v Value
+			return v, false
+		}
+		state.head = rb.add(pos, 1)
+		if rb.state.CompareAndSwap(originalState, state.Uint64()) {
+			return rb.read(pos), true
 		}
-		return v, true
 	}
-	// in case we have some conflict with another goroutine, retry.
-	return rb.Dequeue()
+}
+
+// write stores the value of a claimed position, once the slot was released by the previous lap
+func (rb *RingBuffer[Value]) write(pos uint32, v Value) {
+	s := rb.slot(pos)
+	rb.wait(s, pos)
+	s.value = v
+	s.seq.Store(uint64(rb.add(pos, 1)))
+}
+
+// read loads the value of a claimed position, once it was written
+func (rb *RingBuffer[Value]) read(pos uint32) Value {
+	var empty Value
+	s := rb.slot(pos)
+	rb.wait(s, rb.add(pos, 1))
+	v := s.value
+	s.value = empty
+	s.seq.Store(uint64(rb.add(pos, rb.capacity)))
+	return v
+}
+
+// replace swaps the value of the evicted position with the value of the claimed position
+func (rb *RingBuffer[Value]) replace(pos, evictedPos uint32, v Value) Value {
+	s := rb.slot(pos)
+	rb.wait(s, rb.add(evictedPos, 1))
+	evicted := s.value
+	s.value = v
+	s.seq.Store(uint64(rb.add(pos, 1)))
+	return evicted
+}
+
+// ready checks whether the slot of the given position has reached the given sequence,
+// i.e. the goroutine that claimed the previous position of the slot completed.
+// Positions are claimed only once their slot is ready, so operations are not queued behind in-flight operations.
+func (rb *RingBuffer[Value]) ready(pos, seq uint32) bool {
+	return rb.slot(pos).seq.Load() == uint64(seq)
+}
+
+// wait spins until the slot reaches the given sequence.
+// It is a safeguard, as slots are checked before their positions are claimed.
+func (rb *RingBuffer[Value]) wait(s *slot[Value], seq uint32) {
+	for s.seq.Load() != uint64(seq) {
+		runtime.Gosched()
+	}
+}
+
+func (rb *RingBuffer[Value]) slot(pos uint32) *slot[Value] {
+	return &rb.elements[uint64(pos)%rb.capacity]
+}
+
+func (rb *RingBuffer[Value]) add(pos uint32, n uint64) uint32 {
+	return uint32((uint64(pos) + n) % rb.wrap)
+}
+
+func (rb *RingBuffer[Value]) size(state ringBufferState) int {
+	return int((uint64(state.tail) + rb.wrap - uint64(state.head)) % rb.wrap)
+}
+
+// NewSharded creates a ring buffer with a shard per processor (or the given number of shards),
+// to reduce contention between goroutines that are running on different cores.
+// The capacity is split between the shards, and full or empty shards are falling back to other shards.
+// NOTE: FIFO order is relaxed, it is kept only between elements of the same shard.
+func NewSharded[Value any](shards int, opts ...options.Option[core.Options]) core.Queue[Value] {
+	o := options.Apply(nil, opts...)
+	capacity := shard.Capacity(int(o.Capacity()), shards)
+	return shard.NewQueue(shards, func() core.Queue[Value] {
+		return New[Value](append(slices.Clone(opts), core.WithCapacity(capacity))...)
+	})
 }
@@ -1,29 +1,37 @@
 package ringbuffer
 
 import (
+	"math"
 	"testing"
 
+	"github.com/amirylm/lockfree/core"
 	"github.com/stretchr/testify/require"
 )
 
 func TestRingBufferState(t *testing.T) {
-	require.Equal(t, uint64(0x80000000), newState(0).Uint64())
-	require.True(t, newState(0).Empty())
-	require.Equal(t, uint64(0x80000001), newState(1).Uint64())
-	state := &ringBufferState{
+	require.Equal(t, uint64(0), newState(0).Uint64())
+	require.Equal(t, uint64(1), newState(1).Uint64())
+	state := ringBufferState{
 		head: 1,
 		tail: 3,
-		full: false,
 	}
-	require.False(t, state.Empty())
-	state.full = true
-	state.head = uint32(12800)
-	state.tail = uint32(12800)
+	require.Equal(t, state, newState(state.Uint64()))
+	// positions are not limited to 16 bits
+	state.head = uint32(128000)
+	state.tail = uint32(math.MaxUint32)
 	statecp := newState(state.Uint64())
-	require.True(t, statecp.full)
-	require.Equal(t, uint32(12800), statecp.head)
-	require.Equal(t, uint32(12800), statecp.tail)
+	require.Equal(t, uint32(128000), statecp.head)
+	require.Equal(t, uint32(math.MaxUint32), statecp.tail)
 	statecp.head = uint32(4)
 	statecp = newState(statecp.Uint64())
 	require.Equal(t, uint32(4), statecp.head)
 }
+
+func TestRingBuffer_Wrap(t *testing.T) {
+	rb := New[int](core.WithCapacity(3)).(*RingBuffer[int])
+	// positions wrap at a multiple of the capacity, so they keep mapping to the same slots
+	require.Zero(t, rb.wrap%3)
+	require.Equal(t, uint32(0), rb.add(uint32(rb.wrap-1), 1))
+	require.Equal(t, uint32(1), rb.add(uint32(rb.wrap-2), 3))
+	require.Equal(t, 3, rb.size(ringBufferState{head: uint32(rb.wrap - 1), tail: 2}))
+}
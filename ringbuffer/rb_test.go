@@ -3,6 +3,8 @@ package ringbuffer
 import (
 	"context"
 	"math/big"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -55,3 +57,212 @@ func TestRingBuffer_Overflow(t *testing.T) {
 		require.Equal(t, i+overflow+1, v)
 	}
 }
+
+func TestRingBuffer_Sharded(t *testing.T) {
+	// FIFO order is relaxed, therefore only values are asserted
+	factory := func() core.Queue[int] { return NewSharded[int](4, core.WithCapacity(32)) }
+	utils.SanityTest(t, 32, factory, func(i int) int {
+		return i + 1
+	}, func(i, v int) bool {
+		return v > 0 && v <= 32
+	})
+
+	pctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+	nmsgs := 1024
+	w, r := 5, 5
+	reads, writes := utils.ConcurrencyTest(t, pctx, 128, nmsgs, r, w, func() core.Queue[int] {
+		return NewSharded[int](0, core.WithCapacity(128))
+	}, func(i int) int {
+		return i + 1
+	}, func(i int, v int) bool {
+		return v > 0
+	})
+	require.Equal(t, int64(nmsgs*w), writes, "num of writes is wrong")
+	require.Equal(t, int64(nmsgs*r), reads, "num of reads is wrong")
+}
+
+func TestSeqRingBuffer_Sanity_Int(t *testing.T) {
+	factory := func() core.Queue[int] { return NewSeq[int](core.WithCapacity(32)) }
+	utils.SanityTest(t, 32, factory, func(i int) int {
+		return i + 1
+	}, func(i, v int) bool {
+		return v == i+1
+	})
+}
+
+func TestSeqRingBuffer_Concurrency_Bytes(t *testing.T) {
+	pctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+
+	nmsgs := 1024
+	w, r := 5, 5
+	factory := func() core.Queue[[]byte] { return NewSeq[[]byte](core.WithCapacity(30)) }
+	reads, writes := utils.ConcurrencyTest(t, pctx, 128, nmsgs, r, w, factory, func(i int) []byte {
+		return append([]byte{1, 1}, big.NewInt(int64(i)).Bytes()...)
+	}, func(i int, v []byte) bool {
+		return len(v) > 1 && v[0] == 1
+	})
+	require.Equal(t, int64(nmsgs*w), writes, "num of writes is wrong")
+	require.Equal(t, int64(nmsgs*r), reads, "num of reads is wrong")
+}
+
+func TestSeqRingBuffer_Overflow(t *testing.T) {
+	rb := NewSeq[int](core.WithCapacity(100), core.WithOverride(true))
+	overflow := 5
+	n := 100
+	for i := 0; i < n+overflow; i++ {
+		require.True(t, rb.Enqueue(i+1), "failed to enqueue element in index %d", i)
+	}
+	require.True(t, rb.Full())
+	for i := 0; i < n; i++ {
+		v, ok := rb.Dequeue()
+		require.True(t, ok, "failed to read element in index %d", i)
+		require.Equal(t, i+overflow+1, v)
+	}
+	require.True(t, rb.Empty())
+}
+
+func TestSeqRingBuffer_Allocs(t *testing.T) {
+	type point struct {
+		x, y int64
+	}
+	ints := NewSeq[int](core.WithCapacity(8))
+	points := NewSeq[point](core.WithCapacity(8))
+	allocs := testing.AllocsPerRun(1000, func() {
+		ints.Enqueue(1)
+		ints.Dequeue()
+		points.Enqueue(point{1, 2})
+		points.Dequeue()
+	})
+	require.Zero(t, allocs)
+
+	rb := New[int](core.WithCapacity(8), core.WithOverride(true))
+	allocs = testing.AllocsPerRun(1000, func() {
+		rb.Enqueue(1)
+		rb.Dequeue()
+	})
+	require.Zero(t, allocs)
+}
+
+func TestRingBuffer_EnqueueOverwrite(t *testing.T) {
+	var evictedByCallback []int
+	rb := NewOverwrite[int](core.WithCapacity(4), core.WithOnEvict(func(v int) {
+		evictedByCallback = append(evictedByCallback, v)
+	}))
+
+	for i := 1; i <= 3; i++ {
+		_, didEvict := rb.EnqueueOverwrite(i)
+		require.False(t, didEvict)
+	}
+	require.True(t, rb.Enqueue(4))
+	require.True(t, rb.Full())
+	for i := 5; i <= 10; i++ {
+		evicted, didEvict := rb.EnqueueOverwrite(i)
+		require.True(t, didEvict)
+		require.Equal(t, i-4, evicted)
+		require.Equal(t, 4, rb.Size())
+	}
+	require.Equal(t, []int{1, 2, 3, 4, 5, 6}, evictedByCallback)
+	for i := 7; i <= 10; i++ {
+		v, ok := rb.Dequeue()
+		require.True(t, ok)
+		require.Equal(t, i, v)
+	}
+	require.True(t, rb.Empty())
+}
+
+func TestRingBuffer_Options(t *testing.T) {
+	// Enqueue doesn't override unless configured
+	rb := New[int](core.WithCapacity(2))
+	require.True(t, rb.Enqueue(1))
+	require.True(t, rb.Enqueue(2))
+	require.False(t, rb.Enqueue(3))
+
+	// capacity defaults to 1024
+	for _, c := range []int{0, -1} {
+		require.Equal(t, uint64(defaultCapacity), New[int](core.WithCapacity(c)).(*RingBuffer[int]).capacity)
+		require.Len(t, NewSeq[int](core.WithCapacity(c)).(*SeqRingBuffer[int]).slots, defaultCapacity)
+	}
+
+	require.Panics(t, func() {
+		New[int](core.WithOnEvict(func(string) {}))
+	}, "eviction callback of a different type")
+}
+
+func TestRingBuffer_OverwriteStress(t *testing.T) {
+	capacity, writers, readers, n := 16, 8, 4, 2000
+
+	t.Run("no readers", func(t *testing.T) {
+		var callbacks atomic.Int64
+		rb := NewOverwrite[int](core.WithCapacity(capacity), core.WithOnEvict(func(int) {
+			callbacks.Add(1)
+		}))
+		var evictions atomic.Int64
+		var wg sync.WaitGroup
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < n; i++ {
+					if _, didEvict := rb.EnqueueOverwrite(i); didEvict {
+						evictions.Add(1)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		// exactly one eviction per write that found the buffer full
+		require.Equal(t, int64(writers*n-capacity), evictions.Load())
+		require.Equal(t, evictions.Load(), callbacks.Load())
+		require.Equal(t, capacity, rb.Size())
+	})
+
+	t.Run("with readers", func(t *testing.T) {
+		rb := NewOverwrite[int](core.WithCapacity(capacity))
+		seen := make([]atomic.Int32, writers*n)
+		var wwg, rwg sync.WaitGroup
+		done := make(chan struct{})
+		for w := 0; w < writers; w++ {
+			wwg.Add(1)
+			go func(w int) {
+				defer wwg.Done()
+				for i := 0; i < n; i++ {
+					if evicted, didEvict := rb.EnqueueOverwrite(w*n + i); didEvict {
+						seen[evicted].Add(1)
+					}
+				}
+			}(w)
+		}
+		for r := 0; r < readers; r++ {
+			rwg.Add(1)
+			go func() {
+				defer rwg.Done()
+				for {
+					select {
+					case <-done:
+						return
+					default:
+					}
+					if v, ok := rb.Dequeue(); ok {
+						seen[v].Add(1)
+					}
+				}
+			}()
+		}
+		wwg.Wait()
+		close(done)
+		rwg.Wait()
+		for {
+			v, ok := rb.Dequeue()
+			if !ok {
+				break
+			}
+			seen[v].Add(1)
+		}
+		// every value was either read or evicted, exactly once
+		for v := range seen {
+			require.Equal(t, int32(1), seen[v].Load(), "value %d", v)
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package ringbuffer
+
+import (
+	"sync/atomic"
+
+	"github.com/amirylm/go-options"
+	"github.com/amirylm/lockfree/core"
+	"github.com/amirylm/lockfree/internal/pad"
+)
+
+// NewSeq creates a new SeqRingBuffer, the capacity defaults to 1024.
+// Overriding requires advancing head and tail together, therefore a RingBuffer is returned in case override is set.
+func NewSeq[Value any](opts ...options.Option[core.Options]) core.Queue[Value] {
+	o := options.Apply(nil, opts...)
+	if o.Override() {
+		return New[Value](opts...)
+	}
+	capacity := defaultCapacity
+	if c := int(o.Capacity()); c > 0 {
+		capacity = c
+	}
+	rb := &SeqRingBuffer[Value]{
+		slots:    make([]slot[Value], capacity),
+		capacity: uint64(capacity),
+	}
+	for i := range rb.slots {
+		rb.slots[i].seq.Store(uint64(i))
+	}
+	return rb
+}
+
+// SeqRingBuffer is a lock-free queue based on a ring buffer, where values are stored inline
+// in the slots, therefore enqueue and dequeue are not allocating (for value types).
+// Each slot is guarded by a sequence number (Vyukov's bounded MPMC queue):
+// a slot is writable at position p when its sequence is p, and readable when its sequence is p+1.
+// Once read, the sequence is set to p+capacity, so the slot is writable in the next lap.
+type SeqRingBuffer[Value any] struct {
+	_ pad.CacheLine
+	// tail is the next position to write
+	tail atomic.Uint64
+	_    pad.CacheLine
+	// head is the next position to read
+	head atomic.Uint64
+	_    pad.CacheLine
+
+	slots    []slot[Value]
+	capacity uint64
+}
+
+type slot[Value any] struct {
+	seq   atomic.Uint64
+	value Value
+}
+
+func (rb *SeqRingBuffer[Value]) Enqueue(v Value) bool {
+	pos := rb.tail.Load()
+	for {
+		s := &rb.slots[pos%rb.capacity]
+		seq := s.seq.Load()
+		switch dif := int64(seq - pos); {
+		case dif == 0:
+			if rb.tail.CompareAndSwap(pos, pos+1) {
+				s.value = v
+				s.seq.Store(pos + 1)
+				return true
+			}
+		case dif < 0:
+			// the slot was not read in the previous lap, i.e. the buffer is full
+			return false
+		}
+		pos = rb.tail.Load()
+	}
+}
+
+func (rb *SeqRingBuffer[Value]) Dequeue() (Value, bool) {
+	var empty Value
+	pos := rb.head.Load()
+	for {
+		s := &rb.slots[pos%rb.capacity]
+		seq := s.seq.Load()
+		switch dif := int64(seq - (pos + 1)); {
+		case dif == 0:
+			if rb.head.CompareAndSwap(pos, pos+1) {
+				v := s.value
+				// releasing the value, so it can be collected in case it holds pointers
+				s.value = empty
+				s.seq.Store(pos + rb.capacity)
+				return v, true
+			}
+		case dif < 0:
+			// the slot was not written yet, i.e. the buffer is empty
+			return empty, false
+		}
+		pos = rb.head.Load()
+	}
+}
+
+func (rb *SeqRingBuffer[Value]) Size() int {
+	head := rb.head.Load()
+	tail := rb.tail.Load()
+	if tail <= head {
+		return 0
+	}
+	return int(min(tail-head, rb.capacity))
+}
+
+func (rb *SeqRingBuffer[Value]) Empty() bool {
+	return rb.Size() == 0
+}
+
+func (rb *SeqRingBuffer[Value]) Full() bool {
+	return rb.Size() == int(rb.capacity)
+}
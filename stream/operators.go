@@ -0,0 +1,230 @@
+package stream
+
+import (
+	"context"
+	"time"
+
+	"github.com/amirylm/go-options"
+	"github.com/amirylm/lockfree/core"
+	"github.com/amirylm/lockfree/idle"
+)
+
+// Stream is a sequence of elements that are flowing through lock-free queues.
+// Each operator consumes the queue of its stream in the background, and produces into the queue of a new stream.
+// Producers are back-pressured once the queues are full.
+type Stream[T any] struct {
+	ctx context.Context
+	q   core.Queue[T]
+	o   *Options
+}
+
+// From creates a stream that is sourced from the given queue,
+// operators are running until the context is done.
+func From[T any](ctx context.Context, q core.Queue[T], opts ...options.Option[Options]) *Stream[T] {
+	return &Stream[T]{
+		ctx: ctx,
+		q:   q,
+		o:   newOptions(opts...),
+	}
+}
+
+// Queue returns the underlying queue of the stream
+func (s *Stream[T]) Queue() core.Queue[T] {
+	return s.q
+}
+
+// To pumps the elements of the stream into the given queue.
+func (s *Stream[T]) To(q core.Queue[T]) {
+	s.run(func(v T, st idle.Strategy) bool {
+		return offer(s.ctx, st, q, v)
+	}, q)
+}
+
+// ForEach calls the given function for every element of the stream.
+func (s *Stream[T]) ForEach(f func(T)) {
+	s.run(func(v T, _ idle.Strategy) bool {
+		f(v)
+		return true
+	})
+}
+
+// run consumes the stream with the configured amount of workers, which are producing into the given queues
+func (s *Stream[T]) run(f func(T, idle.Strategy) bool, outs ...any) {
+	for i := 0; i < s.o.workers; i++ {
+		st := s.o.idleStrategy()
+		watch(s.ctx, st, s.q)
+		watch(s.ctx, st, outs...)
+		go pump(s.ctx, st, func() bool {
+			v, ok := s.q.Dequeue()
+			if !ok {
+				return false
+			}
+			return f(v, st)
+		})
+	}
+}
+
+// via creates a downstream stream, the given function emits elements into it.
+func via[T, U any](s *Stream[T], f func(v T, emit func(U) bool)) *Stream[U] {
+	out := &Stream[U]{
+		ctx: s.ctx,
+		q:   newQueues[U](1, s.o)[0],
+		o:   s.o,
+	}
+	s.run(func(v T, st idle.Strategy) bool {
+		f(v, func(u U) bool {
+			return offer(s.ctx, st, out.q, u)
+		})
+		return true
+	}, out.q)
+	return out
+}
+
+// Map transforms the elements of the stream.
+func Map[T, U any](s *Stream[T], f func(T) U) *Stream[U] {
+	return via(s, func(v T, emit func(U) bool) {
+		emit(f(v))
+	})
+}
+
+// Filter keeps only the elements that the given function accepts.
+func Filter[T any](s *Stream[T], f func(T) bool) *Stream[T] {
+	return via(s, func(v T, emit func(T) bool) {
+		if f(v) {
+			emit(v)
+		}
+	})
+}
+
+// FlatMap transforms each element into zero or more elements.
+func FlatMap[T, U any](s *Stream[T], f func(T) []U) *Stream[U] {
+	return via(s, func(v T, emit func(U) bool) {
+		for _, u := range f(v) {
+			if !emit(u) {
+				return
+			}
+		}
+	})
+}
+
+// Reduce folds each batch of elements into a single value, see Batch and Window.
+func Reduce[T, A any](s *Stream[[]T], init A, f func(A, T) A) *Stream[A] {
+	return via(s, func(batch []T, emit func(A) bool) {
+		acc := init
+		for _, v := range batch {
+			acc = f(acc, v)
+		}
+		emit(acc)
+	})
+}
+
+// Batch groups elements into batches of the given size,
+// a partial batch is emitted once the timeout elapsed since its first element.
+// Batches are collected by a single goroutine, regardless of the workers config.
+func Batch[T any](s *Stream[T], size int, timeout time.Duration) *Stream[[]T] {
+	var started time.Time
+	return collect(s, func(batch []T, now time.Time, wake func(time.Duration)) bool {
+		if len(batch) == 0 {
+			return false
+		}
+		if started.IsZero() {
+			started = now
+			wake(timeout)
+		}
+		if len(batch) >= size || now.Sub(started) >= timeout {
+			started = time.Time{}
+			return true
+		}
+		return false
+	})
+}
+
+// Window groups elements into tumbling windows of the given duration, empty windows are skipped.
+// Windows are collected by a single goroutine, regardless of the workers config.
+func Window[T any](s *Stream[T], d time.Duration) *Stream[[]T] {
+	end := s.o.clock.Now().Add(d)
+	armed := false
+	return collect(s, func(batch []T, now time.Time, wake func(time.Duration)) bool {
+		if now.Before(end) {
+			if len(batch) > 0 && !armed {
+				armed = true
+				wake(end.Sub(now))
+			}
+			return false
+		}
+		for !now.Before(end) {
+			end = end.Add(d)
+		}
+		armed = false
+		return len(batch) > 0
+	})
+}
+
+// collect groups elements into batches, a batch is emitted once ready returns true.
+// ready is called after each element is added to the batch, and while the stream is idle.
+// wake signals the collecting goroutine once the given duration elapses, so time based batches are emitted while idle.
+func collect[T any](s *Stream[T], ready func(batch []T, now time.Time, wake func(time.Duration)) bool) *Stream[[]T] {
+	out := &Stream[[]T]{
+		ctx: s.ctx,
+		q:   newQueues[[]T](1, s.o)[0],
+		o:   s.o,
+	}
+	st := s.o.idleStrategy()
+	watch(s.ctx, st, s.q, out.q)
+	wake := func(d time.Duration) {
+		s.o.clock.AfterFunc(d, st.Signal)
+	}
+	var batch []T
+	go pump(s.ctx, st, func() bool {
+		v, ok := s.q.Dequeue()
+		if ok {
+			batch = append(batch, v)
+		}
+		if ready(batch, s.o.clock.Now(), wake) {
+			if len(batch) > 0 && !offer(s.ctx, st, out.q, batch) {
+				return false
+			}
+			batch = nil
+			return true
+		}
+		return ok
+	})
+	return out
+}
+
+// Throttle limits the stream to n elements per the given period.
+// Elements are not dropped, instead the upstream is back-pressured.
+func Throttle[T any](s *Stream[T], n int, per time.Duration) *Stream[T] {
+	out := &Stream[T]{
+		ctx: s.ctx,
+		q:   newQueues[T](1, s.o)[0],
+		o:   s.o,
+	}
+	st := s.o.idleStrategy()
+	watch(s.ctx, st, s.q, out.q)
+	start := s.o.clock.Now()
+	count := 0
+	armed := false
+	go pump(s.ctx, st, func() bool {
+		if count >= n {
+			if elapsed := s.o.clock.Since(start); elapsed < per {
+				if !armed {
+					// waking up once the period elapses
+					armed = true
+					s.o.clock.AfterFunc(per-elapsed, st.Signal)
+				}
+				return false
+			}
+			start = s.o.clock.Now()
+			count = 0
+			armed = false
+		}
+		v, ok := s.q.Dequeue()
+		if !ok {
+			return false
+		}
+		count++
+		return offer(s.ctx, st, out.q, v)
+	})
+	return out
+}
@@ -0,0 +1,170 @@
+package stream
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/amirylm/lockfree/core"
+	"github.com/amirylm/lockfree/idle"
+	"github.com/amirylm/lockfree/queue"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream_Operators(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	src := queue.New[int](core.WithCapacity(1024))
+	s := From(ctx, src, WithCapacity(16), WithWorkers(4))
+
+	even := Filter(s, func(v int) bool {
+		return v%2 == 0
+	})
+	pairs := FlatMap(even, func(v int) []int {
+		return []int{v, v}
+	})
+	halves := Map(pairs, func(v int) int {
+		return v / 2
+	})
+	sums := Reduce(Batch(halves, 10, time.Millisecond*20), 0, func(acc, v int) int {
+		return acc + v
+	})
+
+	var total, batches atomic.Int64
+	sums.ForEach(func(sum int) {
+		total.Add(int64(sum))
+		batches.Add(1)
+	})
+
+	// 0..98 even numbers, each of them twice and halved
+	fill(t, src, 0, 100)
+	expected := int64(0)
+	for i := 0; i < 100; i += 2 {
+		expected += int64(i)
+	}
+	for total.Load() < expected && ctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	require.Equal(t, expected, total.Load())
+	// partial batches might be emitted if the workers are slow
+	require.GreaterOrEqual(t, batches.Load(), int64(10))
+}
+
+func TestStream_Batch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	src := queue.New[int](core.WithCapacity(64))
+	batches := Batch(From(ctx, src), 10, time.Millisecond*10).Queue()
+
+	fill(t, src, 0, 25)
+	res := drain(ctx, t, batches, 3)
+	require.Equal(t, seq(0, 10), res[0])
+	require.Equal(t, seq(10, 20), res[1])
+	// partial batch is emitted once the timeout elapsed
+	require.Equal(t, seq(20, 25), res[2])
+}
+
+func TestStream_Window(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	src := queue.New[int](core.WithCapacity(64))
+	windows := Window(From(ctx, src), time.Millisecond*20).Queue()
+
+	fill(t, src, 0, 5)
+	time.Sleep(time.Millisecond * 30)
+	fill(t, src, 5, 8)
+
+	var res []int
+	n := 0
+	for len(res) < 8 {
+		res = append(res, drain(ctx, t, windows, 1)[0]...)
+		n++
+	}
+	require.Equal(t, seq(0, 8), res)
+	// elements that were enqueued in different windows are not grouped together
+	require.GreaterOrEqual(t, n, 2)
+}
+
+func TestStream_Throttle(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	src := queue.New[int](core.WithCapacity(64))
+	throttled := Throttle(From(ctx, src), 5, time.Millisecond*50)
+	out := queue.New[int](core.WithCapacity(64))
+	throttled.To(out)
+
+	start := time.Now()
+	fill(t, src, 0, 15)
+	res := drain(ctx, t, out, 15)
+	// 3 periods are needed for 15 elements, the first one starts immediately
+	require.GreaterOrEqual(t, time.Since(start), time.Millisecond*100)
+	require.Equal(t, seq(0, 15), res)
+}
+
+func TestStream_Workers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	src := queue.New[int](core.WithCapacity(1024))
+	out := queue.New[int](core.WithCapacity(1024))
+	Map(From(ctx, src, WithWorkers(8)), func(v int) int {
+		return v * 10
+	}).To(out)
+
+	fill(t, src, 0, 500)
+	res := drain(ctx, t, out, 500)
+	sort.Ints(res)
+	for i, v := range res {
+		require.Equal(t, i*10, v)
+	}
+}
+
+func TestStream_Park(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// parked goroutines are woken up by the queues of the stream, and by timers of time based operators
+	src := NewQueue[int](WithCapacity(8))
+	s := From(ctx, src, WithCapacity(4), WithWorkers(2), WithIdleStrategy(idle.Park))
+	batches := Batch(Throttle(Map(s, func(v int) int {
+		return v * 2
+	}), 10, time.Millisecond*10), 4, time.Millisecond*10)
+	out := NewQueue[int](WithCapacity(4))
+	Reduce(batches, 0, func(acc, v int) int {
+		return acc + v
+	}).To(out)
+
+	go func() {
+		for i := 0; i < 50; i++ {
+			for !src.Enqueue(i) && ctx.Err() == nil {
+				time.Sleep(time.Microsecond)
+			}
+		}
+	}()
+	total := 0
+	for total < 49*50 && ctx.Err() == nil {
+		for _, sum := range drain(ctx, t, out, 1) {
+			total += sum
+		}
+	}
+	require.Equal(t, 49*50, total)
+}
+
+func TestStream_Unwatch(t *testing.T) {
+	src := NewQueue[int](WithCapacity(8))
+	for i := 0; i < 10; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		From(ctx, src, WithIdleStrategy(idle.Park)).ForEach(func(int) {})
+		cancel()
+	}
+	// the strategies of canceled streams are not signaled anymore
+	require.Eventually(t, func() bool {
+		return src.(*signalQueue[int]).waiters.Len() == 0
+	}, time.Second, time.Millisecond)
+}
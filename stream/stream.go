@@ -0,0 +1,233 @@
+package stream
+
+import (
+	"context"
+	"hash/maphash"
+
+	"github.com/amirylm/go-options"
+	"github.com/amirylm/lockfree/clock"
+	"github.com/amirylm/lockfree/core"
+	"github.com/amirylm/lockfree/idle"
+	"github.com/amirylm/lockfree/ringbuffer"
+)
+
+// Options is the configuration of stream helpers
+type Options struct {
+	capacity     int
+	idleStrategy func() idle.Strategy
+	workers      int
+	clock        clock.Clock
+}
+
+// WithCapacity sets the capacity of the created queues, defaults to 1024.
+func WithCapacity(c int) options.Option[Options] {
+	return func(o *Options) {
+		o.capacity = c
+	}
+}
+
+// WithIdleStrategy sets the idle strategy of the pumping goroutines, defaults to idle.Backoff.
+// Pumping goroutines are signaled by the queues of streams, therefore sources must be created with NewQueue
+// when using parking strategies (see idle.Park).
+func WithIdleStrategy(f func() idle.Strategy) options.Option[Options] {
+	return func(o *Options) {
+		o.idleStrategy = f
+	}
+}
+
+// WithWorkers sets the number of goroutines that are running stream operators and sinks, defaults to 1.
+// NOTE: the order of elements is not preserved when running with multiple workers.
+func WithWorkers(n int) options.Option[Options] {
+	return func(o *Options) {
+		o.workers = n
+	}
+}
+
+// WithClock sets the clock that is used by time based stream operators.
+func WithClock(c clock.Clock) options.Option[Options] {
+	return func(o *Options) {
+		o.clock = c
+	}
+}
+
+func newOptions(opts ...options.Option[Options]) *Options {
+	o := options.Apply(nil, opts...)
+	if o.capacity == 0 {
+		o.capacity = 1024
+	}
+	if o.workers == 0 {
+		o.workers = 1
+	}
+	if o.clock == nil {
+		o.clock = clock.New()
+	}
+	if o.idleStrategy == nil {
+		o.idleStrategy = func() idle.Strategy {
+			return idle.Backoff()
+		}
+	}
+	return o
+}
+
+// NewQueue creates a queue that signals the pumping goroutines that are waiting on it,
+// which is required for sources of streams when using parking strategies.
+func NewQueue[T any](opts ...options.Option[Options]) core.Queue[T] {
+	return newQueues[T](1, newOptions(opts...))[0]
+}
+
+func newQueues[T any](n int, o *Options) []core.Queue[T] {
+	qs := make([]core.Queue[T], n)
+	for i := range qs {
+		qs[i] = &signalQueue[T]{Queue: ringbuffer.New[T](core.WithCapacity(o.capacity))}
+	}
+	return qs
+}
+
+// signalQueue signals the idle strategies of the goroutines that are waiting on the queue,
+// once an element is enqueued (consumers) or dequeued (producers that are waiting for space).
+type signalQueue[T any] struct {
+	core.Queue[T]
+	waiters idle.Waiters
+}
+
+func (q *signalQueue[T]) Enqueue(v T) bool {
+	if !q.Queue.Enqueue(v) {
+		return false
+	}
+	q.waiters.Signal()
+	return true
+}
+
+func (q *signalQueue[T]) Dequeue() (T, bool) {
+	v, ok := q.Queue.Dequeue()
+	if ok {
+		q.waiters.Signal()
+	}
+	return v, ok
+}
+
+func (q *signalQueue[T]) watch(ctx context.Context, s idle.Strategy) {
+	w := q.waiters.Add(s)
+	context.AfterFunc(ctx, func() {
+		q.waiters.Remove(w)
+	})
+}
+
+// watch registers the idle strategy of a goroutine that is waiting on the given queues, until the context is done.
+// Queues that were not created by this package are not signaling.
+func watch(ctx context.Context, s idle.Strategy, qs ...any) {
+	for _, q := range qs {
+		if w, ok := q.(interface {
+			watch(context.Context, idle.Strategy)
+		}); ok {
+			w.watch(ctx, s)
+		}
+	}
+}
+
+// Broadcast pumps the elements of the source into n queues, where each queue gets every element.
+// The source is consumed as fast as the slowest consumer.
+func Broadcast[T any](ctx context.Context, src core.Queue[T], n int, opts ...options.Option[Options]) []core.Queue[T] {
+	o := newOptions(opts...)
+	dsts := newQueues[T](n, o)
+	s := o.idleStrategy()
+	watch(ctx, s, src)
+	for _, dst := range dsts {
+		watch(ctx, s, dst)
+	}
+	go pump(ctx, s, func() bool {
+		v, ok := src.Dequeue()
+		if !ok {
+			return false
+		}
+		for _, dst := range dsts {
+			if !offer(ctx, s, dst, v) {
+				return false
+			}
+		}
+		return true
+	})
+	return dsts
+}
+
+// Tee pumps the elements of the source into two queues, see Broadcast.
+func Tee[T any](ctx context.Context, src core.Queue[T], opts ...options.Option[Options]) (core.Queue[T], core.Queue[T]) {
+	dsts := Broadcast(ctx, src, 2, opts...)
+	return dsts[0], dsts[1]
+}
+
+// FanIn merges the given sources into a single queue.
+// The sources are consumed in a round robin, while the order of elements from each source is preserved.
+func FanIn[T any](ctx context.Context, srcs []core.Queue[T], opts ...options.Option[Options]) core.Queue[T] {
+	o := newOptions(opts...)
+	dst := newQueues[T](1, o)[0]
+	s := o.idleStrategy()
+	watch(ctx, s, dst)
+	for _, src := range srcs {
+		watch(ctx, s, src)
+	}
+	go pump(ctx, s, func() bool {
+		moved := false
+		for _, src := range srcs {
+			v, ok := src.Dequeue()
+			if !ok {
+				continue
+			}
+			if !offer(ctx, s, dst, v) {
+				return false
+			}
+			moved = true
+		}
+		return moved
+	})
+	return dst
+}
+
+// Partition routes the elements of the source into n queues by the given key.
+// Elements with the same key are routed to the same queue, in order.
+func Partition[T any](ctx context.Context, src core.Queue[T], n int, key func(T) string, opts ...options.Option[Options]) []core.Queue[T] {
+	o := newOptions(opts...)
+	dsts := newQueues[T](n, o)
+	seed := maphash.MakeSeed()
+	s := o.idleStrategy()
+	watch(ctx, s, src)
+	for _, dst := range dsts {
+		watch(ctx, s, dst)
+	}
+	go pump(ctx, s, func() bool {
+		v, ok := src.Dequeue()
+		if !ok {
+			return false
+		}
+		i := maphash.String(seed, key(v)) % uint64(n)
+		return offer(ctx, s, dsts[i], v)
+	})
+	return dsts
+}
+
+// pump calls step until the context is done, and idles while there is no work.
+func pump(ctx context.Context, s idle.Strategy, step func() bool) {
+	idleCount := 0
+	for ctx.Err() == nil {
+		if step() {
+			idleCount = 0
+			continue
+		}
+		idleCount++
+		s.Idle(ctx, idleCount)
+	}
+}
+
+// offer enqueues the value, and waits while the queue is full.
+// Returns false if the context is done before.
+func offer[T any](ctx context.Context, s idle.Strategy, q core.Queue[T], v T) bool {
+	idleCount := 0
+	for !q.Enqueue(v) {
+		if ctx.Err() != nil {
+			return false
+		}
+		idleCount++
+		s.Idle(ctx, idleCount)
+	}
+	return true
+}
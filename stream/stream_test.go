@@ -0,0 +1,167 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amirylm/lockfree/core"
+	"github.com/amirylm/lockfree/idle"
+	"github.com/amirylm/lockfree/queue"
+	"github.com/stretchr/testify/require"
+)
+
+// drain dequeues n elements from the queue, or fails once the context is done
+func drain[T any](ctx context.Context, t *testing.T, q core.Queue[T], n int) []T {
+	res := drainAsync(ctx, q, n)
+	require.NoError(t, ctx.Err())
+	return res
+}
+
+func drainAsync[T any](ctx context.Context, q core.Queue[T], n int) []T {
+	res := make([]T, 0, n)
+	for len(res) < n && ctx.Err() == nil {
+		v, ok := q.Dequeue()
+		if !ok {
+			time.Sleep(time.Microsecond)
+			continue
+		}
+		res = append(res, v)
+	}
+	return res
+}
+
+func fill(t *testing.T, q core.Queue[int], from, to int) {
+	for i := from; i < to; i++ {
+		require.True(t, q.Enqueue(i))
+	}
+}
+
+func seq(from, to int) []int {
+	res := make([]int, 0, to-from)
+	for i := from; i < to; i++ {
+		res = append(res, i)
+	}
+	return res
+}
+
+func TestBroadcast(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	src := queue.New[int](core.WithCapacity(128))
+	dsts := Broadcast(ctx, src, 3, WithCapacity(8))
+	require.Len(t, dsts, 3)
+	fill(t, src, 0, 100)
+
+	// the slowest consumer back-pressures the source, therefore consumers must run in parallel
+	results := make([][]int, len(dsts))
+	var wg sync.WaitGroup
+	for i, dst := range dsts {
+		wg.Add(1)
+		go func(i int, dst core.Queue[int]) {
+			defer wg.Done()
+			results[i] = drainAsync(ctx, dst, 100)
+		}(i, dst)
+	}
+	wg.Wait()
+	for _, res := range results {
+		require.Equal(t, seq(0, 100), res)
+	}
+}
+
+func TestTee(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	src := queue.New[int](core.WithCapacity(32))
+	a, b := Tee(ctx, src)
+	fill(t, src, 0, 10)
+	require.Equal(t, seq(0, 10), drain(ctx, t, a, 10))
+	require.Equal(t, seq(0, 10), drain(ctx, t, b, 10))
+}
+
+func TestFanIn(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	srcs := make([]core.Queue[int], 4)
+	for i := range srcs {
+		srcs[i] = queue.New[int](core.WithCapacity(64))
+		fill(t, srcs[i], i*100, i*100+50)
+	}
+	dst := FanIn(ctx, srcs, WithCapacity(16))
+
+	res := drain(ctx, t, dst, 200)
+	// the order of each source is preserved
+	last := map[int]int{0: -1, 1: 99, 2: 199, 3: 299}
+	for _, v := range res {
+		src := v / 100
+		require.Greater(t, v, last[src])
+		last[src] = v
+	}
+}
+
+func TestPartition(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	src := queue.New[string](core.WithCapacity(1024))
+	keys := 10
+	for i := 0; i < 100; i++ {
+		require.True(t, src.Enqueue(fmt.Sprintf("%d:%03d", i%keys, i)))
+	}
+	key := func(v string) string {
+		return v[:1]
+	}
+	dsts := Partition(ctx, src, 4, key, WithCapacity(128))
+	require.Len(t, dsts, 4)
+
+	total := 0
+	for total < 100 {
+		require.NoError(t, ctx.Err())
+		total = 0
+		for _, dst := range dsts {
+			total += dst.Size()
+		}
+	}
+	owners := map[string]int{}
+	for i, dst := range dsts {
+		last := map[string]string{}
+		for _, v := range drain(ctx, t, dst, dst.Size()) {
+			k := key(v)
+			if owner, ok := owners[k]; ok {
+				require.Equal(t, owner, i, "key %s was routed to multiple partitions", k)
+			}
+			owners[k] = i
+			require.Greater(t, v, last[k])
+			last[k] = v
+		}
+	}
+	require.Len(t, owners, keys)
+}
+
+func TestBroadcast_Park(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	src := NewQueue[int](WithCapacity(4))
+	a, b := Tee(ctx, src, WithCapacity(2), WithIdleStrategy(idle.Park))
+	dst := FanIn(ctx, []core.Queue[int]{a, b}, WithCapacity(2), WithIdleStrategy(idle.Park))
+
+	go func() {
+		for i := 0; i < 100; i++ {
+			for !src.Enqueue(i) && ctx.Err() == nil {
+				time.Sleep(time.Microsecond)
+			}
+		}
+	}()
+	res := drain(ctx, t, dst, 200)
+	sum := 0
+	for _, v := range res {
+		sum += v
+	}
+	require.Equal(t, 2*99*100/2, sum)
+}
@@ -0,0 +1,326 @@
+package timingwheel
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/amirylm/go-options"
+	"github.com/amirylm/lockfree/clock"
+	"github.com/amirylm/lockfree/core"
+	"github.com/amirylm/lockfree/queue"
+)
+
+// Options is the configuration of the timing wheel
+type Options struct {
+	tick      time.Duration
+	wheelSize int
+	levels    int
+	clock     clock.Clock
+	capacity  int
+}
+
+// WithTick sets the resolution of the timing wheel.
+func WithTick(tick time.Duration) options.Option[Options] {
+	return func(o *Options) {
+		o.tick = tick
+	}
+}
+
+// WithWheelSize sets the number of slots in each level of the wheel.
+func WithWheelSize(size int) options.Option[Options] {
+	return func(o *Options) {
+		o.wheelSize = size
+	}
+}
+
+// WithLevels sets the number of levels (wheels) in the hierarchy.
+func WithLevels(levels int) options.Option[Options] {
+	return func(o *Options) {
+		o.levels = levels
+	}
+}
+
+// WithClock sets the clock that is used by the timing wheel.
+func WithClock(c clock.Clock) options.Option[Options] {
+	return func(o *Options) {
+		o.clock = c
+	}
+}
+
+// WithCapacity sets the capacity of the queue that holds timers that were not placed in the wheel yet.
+func WithCapacity(capacity int) options.Option[Options] {
+	return func(o *Options) {
+		o.capacity = capacity
+	}
+}
+
+const (
+	timerPending int32 = iota
+	timerFired
+	timerStopped
+)
+
+// Timer is a scheduled function call, created by the timing wheel.
+type Timer struct {
+	// expiration is the tick in which the timer expires
+	expiration int64
+	// period is the amount of ticks between calls of periodic timers
+	period int64
+	fn     func()
+	state  atomic.Int32
+}
+
+// Stop prevents the timer from firing.
+// It returns false if the timer already fired or was stopped.
+func (t *Timer) Stop() bool {
+	return t.state.CompareAndSwap(timerPending, timerStopped)
+}
+
+// TimingWheel is a hierarchical timing wheel.
+// Timers are added through a lock-free queue, and placed in the wheel by a single goroutine
+// that advances the wheel, therefore adding or stopping timers never blocks.
+// Timer functions are called on the goroutine that advances the wheel, and should not block.
+type TimingWheel struct {
+	tick  time.Duration
+	size  int64
+	clock clock.Clock
+	start time.Time
+
+	incoming core.Queue[*Timer]
+	// timers is the number of timers that were scheduled and not removed from the wheel yet
+	timers atomic.Int64
+	// wakeup is signaled once a timer is scheduled, while Start is waiting for timers
+	wakeup chan struct{}
+	// advancing protects the fields below, which are owned by the goroutine that advances the wheel
+	advancing atomic.Bool
+
+	current  int64
+	levels   [][][]*Timer
+	overflow []*Timer
+}
+
+// New creates a new timing wheel
+func New(opts ...options.Option[Options]) *TimingWheel {
+	o := options.Apply(nil, opts...)
+	if o.tick == 0 {
+		o.tick = time.Millisecond
+	}
+	if o.wheelSize == 0 {
+		o.wheelSize = 64
+	}
+	if o.levels == 0 {
+		o.levels = 4
+	}
+	if o.clock == nil {
+		o.clock = clock.New()
+	}
+	if o.capacity == 0 {
+		o.capacity = 4096
+	}
+
+	tw := &TimingWheel{
+		tick:     o.tick,
+		size:     int64(o.wheelSize),
+		clock:    o.clock,
+		start:    o.clock.Now(),
+		incoming: queue.New[*Timer](core.WithCapacity(o.capacity)),
+		wakeup:   make(chan struct{}, 1),
+		levels:   make([][][]*Timer, o.levels),
+	}
+	for i := range tw.levels {
+		tw.levels[i] = make([][]*Timer, o.wheelSize)
+	}
+
+	return tw
+}
+
+// Start advances the wheel on every tick, until the context is done.
+// The ticker runs only while there are timers in the wheel, otherwise Start waits for a timer to be scheduled.
+// NOTE: stopped timers are removed once the wheel reaches them, therefore they keep the ticker running until then.
+func (tw *TimingWheel) Start(ctx context.Context) error {
+	for {
+		if tw.timers.Load() == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-tw.wakeup:
+			}
+			continue
+		}
+		if err := tw.run(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// run advances the wheel on every tick, until there are no timers
+func (tw *TimingWheel) run(ctx context.Context) error {
+	ticker := tw.clock.NewTicker(tw.tick)
+	defer ticker.Stop()
+
+	for tw.timers.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+			tw.Advance()
+		}
+	}
+	return nil
+}
+
+// AfterFunc calls f once the given duration elapses.
+func (tw *TimingWheel) AfterFunc(d time.Duration, f func()) *Timer {
+	return tw.schedule(tw.clock.Now().Add(d), 0, f)
+}
+
+// At calls f at the given time.
+func (tw *TimingWheel) At(t time.Time, f func()) *Timer {
+	return tw.schedule(t, 0, f)
+}
+
+// Every calls f every time the given duration elapses, until the timer is stopped.
+func (tw *TimingWheel) Every(d time.Duration, f func()) *Timer {
+	period := int64(d / tw.tick)
+	if period < 1 {
+		period = 1
+	}
+	return tw.schedule(tw.clock.Now().Add(d), period, f)
+}
+
+func (tw *TimingWheel) schedule(t time.Time, period int64, f func()) *Timer {
+	timer := &Timer{
+		expiration: tw.ticks(t),
+		period:     period,
+		fn:         f,
+	}
+	// counted before it is enqueued, so the wheel is not considered empty while the timer is incoming
+	tw.timers.Add(1)
+	for !tw.incoming.Enqueue(timer) {
+		runtime.Gosched()
+	}
+	select {
+	case tw.wakeup <- struct{}{}:
+	default:
+	}
+	return timer
+}
+
+// ticks returns the tick of the given time, rounded up.
+func (tw *TimingWheel) ticks(t time.Time) int64 {
+	d := t.Sub(tw.start)
+	if d <= 0 {
+		return 0
+	}
+	return int64((d + tw.tick - 1) / tw.tick)
+}
+
+// Advance moves the wheel to the current time, and fires expired timers.
+// In case another goroutine is advancing the wheel, Advance waits for it to complete.
+func (tw *TimingWheel) Advance() {
+	for !tw.advancing.CompareAndSwap(false, true) {
+		runtime.Gosched()
+	}
+	defer tw.advancing.Store(false)
+
+	target := tw.ticks(tw.clock.Now())
+	if tw.timers.Load() == 0 && tw.current < target {
+		// the wheel is empty, so there is nothing to fire or cascade in the ticks that passed
+		tw.current = target
+	}
+	tw.drain()
+	for tw.current < target {
+		tw.current++
+		tw.cascade()
+		slot := tw.current % tw.size
+		timers := tw.levels[0][slot]
+		tw.levels[0][slot] = nil
+		for _, t := range timers {
+			tw.fire(t)
+		}
+	}
+}
+
+// drain places the incoming timers in the wheel
+func (tw *TimingWheel) drain() {
+	t, ok := tw.incoming.Dequeue()
+	for ok {
+		tw.place(t)
+		t, ok = tw.incoming.Dequeue()
+	}
+}
+
+// cascade moves timers from higher levels into lower levels, once the lower level completed a round.
+// Higher levels are cascaded first, so their timers can be cascaded again within the same tick.
+func (tw *TimingWheel) cascade() {
+	span := int64(1)
+	for range tw.levels {
+		span *= tw.size
+	}
+	if tw.current%span == 0 {
+		overflow := tw.overflow
+		tw.overflow = nil
+		for _, t := range overflow {
+			tw.place(t)
+		}
+	}
+	for i := len(tw.levels) - 1; i > 0; i-- {
+		span /= tw.size
+		if tw.current%span != 0 {
+			continue
+		}
+		slot := (tw.current / span) % tw.size
+		timers := tw.levels[i][slot]
+		tw.levels[i][slot] = nil
+		for _, t := range timers {
+			tw.place(t)
+		}
+	}
+}
+
+// place puts the timer in the appropriate level and slot, based on the time left until it expires.
+func (tw *TimingWheel) place(t *Timer) {
+	if t.state.Load() != timerPending {
+		tw.timers.Add(-1)
+		return
+	}
+	delta := t.expiration - tw.current
+	if delta <= 0 {
+		tw.fire(t)
+		return
+	}
+	span := int64(1)
+	for i := range tw.levels {
+		if delta < span*tw.size {
+			slot := (t.expiration / span) % tw.size
+			tw.levels[i][slot] = append(tw.levels[i][slot], t)
+			return
+		}
+		span *= tw.size
+	}
+	tw.overflow = append(tw.overflow, t)
+}
+
+// fire calls the timer function, periodic timers are placed back in the wheel.
+func (tw *TimingWheel) fire(t *Timer) {
+	if t.period > 0 {
+		if t.state.Load() != timerPending {
+			tw.timers.Add(-1)
+			return
+		}
+		t.fn()
+		t.expiration += t.period
+		if t.expiration <= tw.current {
+			// skip missed periods
+			t.expiration = tw.current + t.period
+		}
+		tw.place(t)
+		return
+	}
+	tw.timers.Add(-1)
+	if t.state.CompareAndSwap(timerPending, timerFired) {
+		t.fn()
+	}
+}
@@ -0,0 +1,195 @@
+package timingwheel
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/amirylm/lockfree/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimingWheel_AfterFunc(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		levels int
+		delay  time.Duration
+	}{
+		{"first level", 8, 3, time.Millisecond * 5},
+		{"second level", 8, 3, time.Millisecond * 20},
+		{"third level", 8, 3, time.Millisecond * 300},
+		{"overflow", 4, 2, time.Millisecond * 50},
+		{"level boundary", 8, 3, time.Millisecond * 64},
+		{"zero", 8, 3, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clk := clock.NewManual(time.Now())
+			tw := New(WithClock(clk), WithTick(time.Millisecond), WithWheelSize(tc.size), WithLevels(tc.levels))
+
+			var fired atomic.Int32
+			tw.AfterFunc(tc.delay, func() {
+				fired.Add(1)
+			})
+			if tc.delay > 0 {
+				clk.Advance(tc.delay - time.Millisecond)
+				tw.Advance()
+				require.Equal(t, int32(0), fired.Load(), "fired too early")
+			}
+			clk.Advance(time.Millisecond)
+			tw.Advance()
+			require.Equal(t, int32(1), fired.Load(), "didn't fire on time")
+			clk.Advance(tc.delay * 2)
+			tw.Advance()
+			require.Equal(t, int32(1), fired.Load(), "fired more than once")
+		})
+	}
+}
+
+func TestTimingWheel_Stop(t *testing.T) {
+	clk := clock.NewManual(time.Now())
+	tw := New(WithClock(clk))
+
+	var fired atomic.Int32
+	timer := tw.AfterFunc(time.Millisecond*10, func() {
+		fired.Add(1)
+	})
+	clk.Advance(time.Millisecond * 5)
+	tw.Advance()
+	require.True(t, timer.Stop())
+	require.False(t, timer.Stop())
+	clk.Advance(time.Millisecond * 10)
+	tw.Advance()
+	require.Equal(t, int32(0), fired.Load())
+
+	timer = tw.At(clk.Now().Add(time.Millisecond), func() {
+		fired.Add(1)
+	})
+	clk.Advance(time.Millisecond)
+	tw.Advance()
+	require.Equal(t, int32(1), fired.Load())
+	require.False(t, timer.Stop(), "should not stop fired timer")
+}
+
+func TestTimingWheel_Every(t *testing.T) {
+	clk := clock.NewManual(time.Now())
+	tw := New(WithClock(clk), WithWheelSize(8))
+
+	var fired atomic.Int32
+	timer := tw.Every(time.Millisecond*10, func() {
+		fired.Add(1)
+	})
+	for i := 1; i <= 20; i++ {
+		clk.Advance(time.Millisecond * 10)
+		tw.Advance()
+		require.Equal(t, int32(i), fired.Load())
+	}
+	// advancing multiple periods at once
+	clk.Advance(time.Millisecond * 50)
+	tw.Advance()
+	require.Equal(t, int32(25), fired.Load())
+
+	require.True(t, timer.Stop())
+	clk.Advance(time.Millisecond * 50)
+	tw.Advance()
+	require.Equal(t, int32(25), fired.Load())
+}
+
+func TestTimingWheel_Concurrency(t *testing.T) {
+	clk := clock.NewManual(time.Now())
+	tw := New(WithClock(clk), WithWheelSize(16), WithLevels(2))
+
+	var fired atomic.Int32
+	var wg sync.WaitGroup
+	writers, n := 8, 256
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				tw.AfterFunc(time.Millisecond*time.Duration(i), func() {
+					fired.Add(1)
+				})
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				tw.Advance()
+			}
+		}
+	}()
+	wg.Wait()
+	close(done)
+
+	clk.Advance(time.Millisecond * time.Duration(n))
+	tw.Advance()
+	require.Equal(t, int32(writers*n), fired.Load())
+}
+
+func TestTimingWheel_Start(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	tw := New()
+	go func() {
+		_ = tw.Start(ctx)
+	}()
+
+	fired := make(chan struct{})
+	tw.AfterFunc(time.Millisecond*20, func() {
+		close(fired)
+	})
+	select {
+	case <-fired:
+	case <-ctx.Done():
+		require.FailNow(t, "timer didn't fire")
+	}
+}
+
+func TestTimingWheel_StartIdle(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	clk := clock.NewManual(time.Now())
+	tw := New(WithClock(clk), WithTick(time.Millisecond))
+	go func() {
+		_ = tw.Start(ctx)
+	}()
+
+	time.Sleep(time.Millisecond * 10)
+	require.Equal(t, 0, clk.Waiters(), "ticker is running without timers")
+
+	var fired atomic.Int32
+	tw.AfterFunc(time.Millisecond*5, func() {
+		fired.Add(1)
+	})
+	// waiting for the ticker
+	clk.BlockUntil(1)
+	clk.Advance(time.Millisecond * 5)
+	require.Eventually(t, func() bool {
+		return fired.Load() == 1
+	}, time.Second, time.Millisecond)
+	require.Eventually(t, func() bool {
+		return clk.Waiters() == 0
+	}, time.Second, time.Millisecond, "ticker is running after the timers fired")
+
+	// the wheel is started again by the next timer
+	tw.AfterFunc(time.Millisecond*5, func() {
+		fired.Add(1)
+	})
+	clk.BlockUntil(1)
+	clk.Advance(time.Millisecond * 5)
+	require.Eventually(t, func() bool {
+		return fired.Load() == 2
+	}, time.Second, time.Millisecond)
+}